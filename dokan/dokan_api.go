@@ -69,28 +69,57 @@ func Shutdown() error {
 	return errnoToError(err)
 }
 
+// MountPoints returns all volumes mounted by Dokan.
 func MountPoints() ([]*MountPointInfo, error) {
-	var n uint32
-	ret, _, err := syscall.SyscallN(dokanGetMountPointList.Addr(), uintptr(0), uintptr(unsafe.Pointer(&n)))
-	if err != 0 {
-		return nil, errnoToError(err)
-	}
+	return ListMountPoints(MountPointFilter{})
+}
 
-	var mps []*MountPointInfo
-	// TODO: Fix: possible misuse of unsafe.Pointer
-	for _, mp := range unsafe.Slice((*nativeMountPointInfo)(unsafe.Pointer(ret)), n) {
-		mps = append(mps, &MountPointInfo{
-			Type:         mp.Type,
-			MountPoint:   syscall.UTF16ToString(mp.MountPoint[:]),
-			UNCName:      syscall.UTF16ToString(mp.UNCName[:]),
-			DeviceName:   syscall.UTF16ToString(mp.DeviceName[:]),
-			SessionID:    mp.Type,
-			MountOptions: mp.MountOptions,
-		})
+// ListMountPoints returns volumes mounted by Dokan filtered by filter.
+func ListMountPoints(filter MountPointFilter) ([]*MountPointInfo, error) {
+	if dokanGetMountPointList.Find() != nil {
+		return nil, ErrFailedToLoadDokan
+	}
+	var uncOnly uintptr
+	if filter.UNCOnly {
+		uncOnly = 1
+	}
+	var n uint32
+	ret, _, err := syscall.SyscallN(dokanGetMountPointList.Addr(), uncOnly, uintptr(unsafe.Pointer(&n)))
+	if ret == 0 {
+		if n != 0 {
+			return nil, errnoToError(err)
+		}
+		return nil, nil
 	}
 
+	// Copy the list to Go memory before releasing it.
+	list := make([]nativeMountPointInfo, n)
+	copy(list, unsafe.Slice(*(**nativeMountPointInfo)(unsafe.Pointer(&ret)), n))
 	syscall.SyscallN(dokanReleaseMountPointList.Addr(), ret)
-	return mps, errnoToError(err)
+
+	return filterMountPoints(decodeMountPoints(list), filter), nil
+}
+
+// UsedDriveLetters returns drive letters used by the system or Dokan. e.g. "CDX"
+func UsedDriveLetters() (string, error) {
+	drives, err := windows.GetLogicalDrives()
+	if err != nil {
+		return "", err
+	}
+	if mps, err := MountPoints(); err == nil {
+		for _, mp := range mps {
+			if l := mp.DriveLetter(); l != "" {
+				drives |= 1 << (l[0] - 'A')
+			}
+		}
+	}
+	var used []byte
+	for i := 0; i < 26; i++ {
+		if drives&(1<<i) != 0 {
+			used = append(used, byte('A'+i))
+		}
+	}
+	return string(used), nil
 }
 
 func CreateFileSystem(options *DokanOptions, operations *DokanOperations) (MountHandle, error) {
@@ -122,15 +151,79 @@ func CreateFileSystem(options *DokanOptions, operations *DokanOperations) (Mount
 	return MountHandle(handle), nil
 }
 
+// waitForFileSystemClosed returns true if the file system is closed within timeout milliseconds.
+func waitForFileSystemClosed(handle MountHandle, timeout uint32) (bool, error) {
+	ret, _, err := syscall.SyscallN(dokanWaitForFileSystemClosed.Addr(), uintptr(handle), uintptr(timeout))
+	if uint32(ret) == windows.WAIT_FAILED {
+		if err != 0 {
+			return false, err
+		}
+		return false, ErrDokan
+	}
+	return uint32(ret) == windows.WAIT_OBJECT_0, nil
+}
+
+func removeMountPoint(mountPoint string) error {
+	p, err := syscall.UTF16PtrFromString(mountPoint)
+	if err != nil {
+		return err
+	}
+	ret, _, err := syscall.SyscallN(dokanRemoveMountPoint.Addr(), uintptr(unsafe.Pointer(p)))
+	if ret == 0 {
+		if err != syscall.Errno(0) {
+			return err
+		}
+		return ErrBadMountPoint
+	}
+	return nil
+}
+
 func closeHandle(handle MountHandle) error {
 	_, _, err := syscall.SyscallN(dokanCloseHandle.Addr(), uintptr(handle))
 	return errnoToError(err)
 }
 
-func UTF16FromString(s string) ([]uint16, error) {
-	return syscall.UTF16FromString(s)
+// ResetTimeout extends the timeout of the request to timeout milliseconds.
+func ResetTimeout(timeout uint32, finfo *FileInfo) bool {
+	ret, _, _ := syscall.SyscallN(dokanResetTimeout.Addr(), uintptr(timeout), uintptr(unsafe.Pointer(finfo)))
+	return ret != 0
 }
 
-func UTF16PtrFromString(s string) (*uint16, error) {
-	return syscall.UTF16PtrFromString(s)
+// GetRequestor returns the process and the user which issued the request.
+// Requestor is not nil even if an error occurred and contains the information retrieved successfully.
+func GetRequestor(finfo *FileInfo) (*Requestor, error) {
+	r := &Requestor{ProcessID: finfo.ProcessId}
+	if dokanOpenRequestorToken.Find() != nil {
+		return r, ErrFailedToLoadDokan
+	}
+
+	var firstErr error
+	if h, err := windows.OpenProcess(windows.PROCESS_QUERY_LIMITED_INFORMATION, false, finfo.ProcessId); err == nil {
+		buf := make([]uint16, windows.MAX_LONG_PATH)
+		size := uint32(len(buf))
+		if err := windows.QueryFullProcessImageName(h, 0, &buf[0], &size); err == nil {
+			r.ImageName = windows.UTF16ToString(buf[:size])
+		} else {
+			firstErr = err
+		}
+		windows.CloseHandle(h)
+	} else {
+		firstErr = err
+	}
+
+	ret, _, errno := syscall.SyscallN(dokanOpenRequestorToken.Addr(), uintptr(unsafe.Pointer(finfo)))
+	token := windows.Token(ret)
+	if windows.Handle(token) == windows.InvalidHandle || token == 0 {
+		if firstErr == nil {
+			firstErr = errnoToError(errno)
+		}
+		return r, firstErr
+	}
+	defer token.Close()
+	if user, err := token.GetTokenUser(); err == nil {
+		r.UserSID = user.User.Sid.String()
+	} else if firstErr == nil {
+		firstErr = err
+	}
+	return r, firstErr
 }
@@ -0,0 +1,108 @@
+package dokan
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// DefaultTimeout is the request timeout used by Dokan when DokanOptions.Timeout is 0.
+const DefaultTimeout = 15 * time.Second
+
+type requestContextKey struct{}
+
+// requestContext is a context that expires after the timeout of the Dokan request.
+// Unlike context.WithDeadline, its deadline can be extended by ReportProgress().
+type requestContext struct {
+	context.Context
+	cancel   context.CancelFunc
+	finfo    *FileInfo
+	timeout  time.Duration
+	lock     sync.Mutex
+	deadline time.Time
+	timer    *time.Timer
+	expired  bool
+}
+
+// NewRequestContext returns a context for the request.
+// The context is canceled when the request timed out or the file system is unmounted.
+func NewRequestContext(finfo *FileInfo) (context.Context, context.CancelFunc) {
+	parent := context.Background()
+	timeout := DefaultTimeout
+	if finfo != nil && finfo.DokanOptions != nil {
+		if finfo.DokanOptions.Timeout != 0 {
+			timeout = time.Duration(finfo.DokanOptions.Timeout) * time.Millisecond
+		}
+		if mi := (*MountInfo)(finfo.DokanOptions.GlobalContext); mi != nil && mi.ctx != nil {
+			parent = mi.ctx
+		}
+	}
+
+	ctx, cancel := context.WithCancel(parent)
+	c := &requestContext{Context: ctx, cancel: cancel, finfo: finfo, timeout: timeout, deadline: time.Now().Add(timeout)}
+	c.timer = time.AfterFunc(timeout, c.expire)
+	return c, func() {
+		c.timer.Stop()
+		cancel()
+	}
+}
+
+func (c *requestContext) expire() {
+	c.lock.Lock()
+	if time.Now().Before(c.deadline) {
+		// extended by ReportProgress()
+		c.timer.Reset(time.Until(c.deadline))
+		c.lock.Unlock()
+		return
+	}
+	c.expired = true
+	c.lock.Unlock()
+	c.cancel()
+}
+
+func (c *requestContext) Deadline() (time.Time, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if d, ok := c.Context.Deadline(); ok && d.Before(c.deadline) {
+		return d, true
+	}
+	return c.deadline, true
+}
+
+func (c *requestContext) Err() error {
+	err := c.Context.Err()
+	if err == nil {
+		return nil
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.expired {
+		return context.DeadlineExceeded
+	}
+	return err
+}
+
+func (c *requestContext) Value(key interface{}) interface{} {
+	if key == (requestContextKey{}) {
+		return c
+	}
+	return c.Context.Value(key)
+}
+
+// ReportProgress notifies that a long operation of the request is still making progress.
+// It extends the deadline of the context returned by NewRequestContext() and resets the timeout of the Dokan driver.
+func ReportProgress(ctx context.Context) {
+	c, ok := ctx.Value(requestContextKey{}).(*requestContext)
+	if !ok {
+		return
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.expired || time.Until(c.deadline) > c.timeout/2 {
+		return
+	}
+	c.deadline = time.Now().Add(c.timeout)
+	if c.finfo != nil && c.finfo.DokanOptions != nil {
+		ResetTimeout(uint32(c.timeout/time.Millisecond), c.finfo)
+	}
+}
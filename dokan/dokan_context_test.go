@@ -0,0 +1,50 @@
+package dokan
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestRequestContext(t *testing.T) {
+	finfo := &FileInfo{DokanOptions: &DokanOptions{Timeout: 50}}
+	ctx, cancel := NewRequestContext(finfo)
+	defer cancel()
+
+	if d, ok := ctx.Deadline(); !ok || time.Until(d) > 50*time.Millisecond {
+		t.Error("Deadline() should be set from DokanOptions.Timeout: ", d, ok)
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	ReportProgress(ctx)
+	time.Sleep(30 * time.Millisecond)
+	if err := ctx.Err(); err != nil {
+		t.Error("Err() should be nil after ReportProgress()", err)
+	}
+
+	select {
+	case <-ctx.Done():
+	case <-time.After(time.Second):
+		t.Fatal("context should be expired")
+	}
+	if ctx.Err() != context.DeadlineExceeded {
+		t.Error("Err() should be DeadlineExceeded: ", ctx.Err())
+	}
+	if ErrorToNTStatus(ctx.Err()) != STATUS_IO_TIMEOUT {
+		t.Error("ErrorToNTStatus() should return STATUS_IO_TIMEOUT")
+	}
+}
+
+func TestRequestContext_Cancel(t *testing.T) {
+	ctx, cancel := NewRequestContext(&FileInfo{})
+	if ctx.Err() != nil {
+		t.Error("Err() should be nil")
+	}
+	cancel()
+	if ctx.Err() != context.Canceled {
+		t.Error("Err() should be Canceled: ", ctx.Err())
+	}
+	if ErrorToNTStatus(ctx.Err()) != STATUS_CANCELLED {
+		t.Error("ErrorToNTStatus() should return STATUS_CANCELLED")
+	}
+}
@@ -1,8 +1,10 @@
 package dokan
 
 import (
+	"context"
 	"path/filepath"
 	"sync"
+	"time"
 	"unsafe"
 )
 
@@ -35,13 +37,84 @@ type FileHandle interface {
 	CloseFile(*FileInfo)
 }
 
+// Flusher is an optional interface for FileHandle to flush buffered data.
+type Flusher interface {
+	Flush() error
+}
+
+// LinkHandle is an optional interface for FileHandle to create a hard link.
+// NOTE: Dokan doesn't forward FileLinkInformation to user mode for now.
+// Middlewares and tools can use this interface. FileHandleFuncs and Recorder forward it.
+type LinkHandle interface {
+	CreateHardLink(newname string, replaceIfExisting bool, finfo *FileInfo) NTStatus
+}
+
+// createHardLink calls f.CreateHardLink if implemented.
+func createHardLink(f FileHandle, newname string, replaceIfExisting bool, finfo *FileInfo) NTStatus {
+	if f, ok := f.(LinkHandle); ok {
+		return f.CreateHardLink(newname, replaceIfExisting, finfo)
+	}
+	return STATUS_NOT_SUPPORTED
+}
+
 type MountInfo struct {
 	disk        Disk
+	mountPoint  string
 	instance    MountHandle
 	openedFiles map[unsafe.Pointer]struct{}
 	mounted     sync.WaitGroup
 	lock        sync.Mutex
 	options     *DokanOptions
+	buffers     [][]uint16 // referenced by options
+	ctx         context.Context
+	cancel      context.CancelFunc
+
+	done        chan struct{}
+	err         error
+	closing     bool
+	draining    bool
+	waiterDone  chan struct{}
+	unmountOnce sync.Once
+
+	observer Observer
+	inflight sync.Map // *FileInfo -> *opEvent
+	metrics  metrics
+}
+
+func newMountInfo(d Disk) *MountInfo {
+	mi := &MountInfo{disk: d, openedFiles: map[unsafe.Pointer]struct{}{}, done: make(chan struct{})}
+	mi.ctx, mi.cancel = context.WithCancel(context.Background())
+	return mi
+}
+
+// setUnmounted is called when the file system is unmounted.
+func (m *MountInfo) setUnmounted() {
+	m.unmountOnce.Do(func() {
+		m.lock.Lock()
+		if !m.closing {
+			m.err = ErrUnmounted
+		}
+		m.lock.Unlock()
+		m.cancel() // cancel pending requests
+		close(m.done)
+	})
+}
+
+// waitClosed waits for the file system to be closed until Close() is called.
+func (m *MountInfo) waitClosed() {
+	defer close(m.waiterDone)
+	for {
+		m.lock.Lock()
+		closing := m.closing
+		m.lock.Unlock()
+		if closing {
+			return
+		}
+		if closed, err := waitForFileSystemClosed(m.instance, 500); closed || err != nil {
+			m.setUnmounted()
+			return
+		}
+	}
 }
 
 func (m *MountInfo) addFile(f unsafe.Pointer) {
@@ -60,6 +133,34 @@ func (m *MountInfo) Disk() Disk {
 	return m.disk
 }
 
+// MountPoint returns the mount point of this filesystem. e.g. X:\
+// It returns empty string for network volumes without a drive letter.
+func (m *MountInfo) MountPoint() string {
+	return m.mountPoint
+}
+
+// Done returns a channel that is closed when the file system is unmounted.
+func (m *MountInfo) Done() <-chan struct{} {
+	return m.done
+}
+
+// Err returns ErrUnmounted if the file system is unmounted by other than Close().
+// e.g. ejected by the user or the Dokan driver is stopped.
+// It returns nil until Done() is closed or if Close() is called.
+func (m *MountInfo) Err() error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	return m.err
+}
+
+// UNCName returns UNC name of the network volume. e.g. \\server\share
+func (m *MountInfo) UNCName() string {
+	if m.options == nil || m.options.UNCName == nil {
+		return ""
+	}
+	return uncNameFromNative(utf16PtrToString((*uint16)(m.options.UNCName)))
+}
+
 // OpenedFileCount returns number of files currently open in this filesystem.
 func (m *MountInfo) OpenedFileCount() int {
 	m.lock.Lock()
@@ -70,11 +171,78 @@ func (m *MountInfo) OpenedFileCount() int {
 // Close close MountInfo to unmount this filesystem.
 // MountInfo must be closed when it is no longer needed.
 func (mi *MountInfo) Close() error {
+	mi.lock.Lock()
+	mi.closing = true
+	mi.lock.Unlock()
+	if mi.waiterDone != nil {
+		<-mi.waiterDone // MountHandle should not be closed while waiting.
+	}
+	mi.cancel() // Pending requests must be canceled before DokanCloseFileSystem waits for them.
 	err := mi.instance.Close()
+	mi.setUnmounted()
 	unregisterInstance(mi)
 	return err
 }
 
+// Shutdown unmounts this filesystem gracefully.
+// It stops accepting new files and waits until all opened files are closed or ctx is done.
+// Then it unmounts the filesystem and flushes remaining files.
+// It returns ctx.Err() if ctx is done before all files are closed.
+func (mi *MountInfo) Shutdown(ctx context.Context) error {
+	err := mi.drain(ctx)
+	if mi.mountPoint != "" {
+		_ = removeMountPoint(mi.mountPoint)
+	}
+	cerr := mi.Close()
+	// Remaining files are flushed after Close() because Dokan may still be calling Cleanup or WriteFile on them until then.
+	mi.flushOpenedFiles()
+	if err == nil {
+		err = cerr
+	}
+	return err
+}
+
+func (mi *MountInfo) drain(ctx context.Context) error {
+	mi.lock.Lock()
+	mi.draining = true
+	mi.lock.Unlock()
+
+	ticker := time.NewTicker(50 * time.Millisecond)
+	defer ticker.Stop()
+	var err error
+	for err == nil && mi.OpenedFileCount() > 0 {
+		select {
+		case <-ctx.Done():
+			err = ctx.Err()
+		case <-mi.done:
+			return nil
+		case <-ticker.C:
+		}
+	}
+	return err
+}
+
+// flushOpenedFiles flushes the files which are not closed. It must not be called while Dokan is running.
+func (mi *MountInfo) flushOpenedFiles() {
+	mi.lock.Lock()
+	var files []FileHandle
+	for f := range mi.openedFiles {
+		files = append(files, *(*FileHandle)(f))
+	}
+	mi.lock.Unlock()
+	for _, f := range files {
+		if f, ok := f.(Flusher); ok {
+			_ = f.Flush()
+		}
+	}
+}
+
+func (m *MountInfo) isDraining() bool {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	return m.draining
+}
+
 // NotifyCreate notify file create event.
 func (m *MountInfo) NotifyCreate(path string, isDir bool) error {
 	return NotifyCreate(m.instance, path, isDir)
@@ -126,34 +294,54 @@ func unregisterInstance(mi *MountInfo) {
 	}
 }
 
+// MountDisk mounts d on mountPoint with DOKAN_OPTION_* flags.
 func MountDisk(mountPoint string, d Disk, optionFlags uint32) (*MountInfo, error) {
-	mi := &MountInfo{disk: d, openedFiles: map[unsafe.Pointer]struct{}{}}
+	return MountDiskWithOptions(mountPoint, d, &Options{Flags: optionFlags})
+}
+
+// MountDiskWithOptions mounts d on mountPoint.
+// mountPoint can be empty if opt.UNCName is specified.
+func MountDiskWithOptions(mountPoint string, d Disk, opt *Options) (*MountInfo, error) {
+	if opt == nil {
+		opt = &Options{}
+	}
+	if err := opt.Validate(); err != nil {
+		return nil, err
+	}
+	if mountPoint == "" && opt.UNCName == "" {
+		return nil, ErrBadMountPoint
+	}
+	mi := newMountInfo(d)
+	mi.observer = opt.Observer
 	if err := registerInstance(mi); err != nil {
 		return nil, err
 	}
-	full, err := filepath.Abs(mountPoint)
-	if err == nil {
-		mountPoint = full
+	if mountPoint != "" {
+		full, err := filepath.Abs(mountPoint)
+		if err == nil {
+			mountPoint = full
+		}
 	}
-	path, err := UTF16PtrFromString(mountPoint)
+	mi.mountPoint = mountPoint
+	options, buffers, err := opt.nativeOptions(mountPoint)
 	if err != nil {
+		unregisterInstance(mi)
 		return nil, err
 	}
-	options := &DokanOptions{
-		Version:       DOKAN_MINIMUM_COMPATIBLE_VERSION,
-		GlobalContext: unsafe.Pointer(mi),
-		MountPoint:    unsafe.Pointer(path),
-		Options:       optionFlags,
-	}
+	options.GlobalContext = unsafe.Pointer(mi)
+	mi.options = options
+	mi.buffers = buffers
 
 	mi.mounted.Add(1)
 	instance, err := CreateFileSystem(options, initDokanOperations())
 	if err != nil {
+		mi.cancel()
 		unregisterInstance(mi)
 		return nil, err
 	}
 	mi.instance = instance
-	mi.options = options
 	mi.mounted.Wait()
+	mi.waiterDone = make(chan struct{})
+	go mi.waitClosed()
 	return mi, nil
 }
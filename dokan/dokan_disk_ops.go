@@ -4,7 +4,6 @@
 package dokan
 
 import (
-	"log"
 	"syscall"
 	"unsafe"
 )
@@ -14,12 +13,12 @@ func initDokanOperations() *DokanOperations {
 	defer instancesLock.Unlock()
 	if dokanOperations == nil {
 		dokanOperations = &DokanOperations{
-			ZwCreateFile: syscall.NewCallback(zwCreateFile),
-			Cleanup:      syscall.NewCallback(cleanup),
-			CloseFile:    syscall.NewCallback(closeFile),
-			ReadFile:     syscall.NewCallback(readFile),
-			WriteFile:    syscall.NewCallback(writeFile),
-			// FlushFileBuffers:   debugCallback,
+			ZwCreateFile:       syscall.NewCallback(zwCreateFile),
+			Cleanup:            syscall.NewCallback(cleanup),
+			CloseFile:          syscall.NewCallback(closeFile),
+			ReadFile:           syscall.NewCallback(readFile),
+			WriteFile:          syscall.NewCallback(writeFile),
+			FlushFileBuffers:   syscall.NewCallback(flushFileBuffers),
 			GetFileInformation: syscall.NewCallback(getFileInformation),
 			FindFiles:          syscall.NewCallback(findFiles),
 			// FindFilesWithPattern: debugCallback,
@@ -29,7 +28,7 @@ func initDokanOperations() *DokanOperations {
 			DeleteDirectory:   syscall.NewCallback(deleteDir),
 			MoveFile:          syscall.NewCallback(moveFile),
 			SetEndOfFile:      syscall.NewCallback(setEndOfFile),
-			SetAllocationSize: syscall.NewCallback(setEndOfFile),
+			SetAllocationSize: syscall.NewCallback(setAllocationSizeCallback),
 			// LockFile:             debugCallback,
 			// UnlockFile:           debugCallback,
 			// GetFileSecurity:      debugCallback,
@@ -38,16 +37,12 @@ func initDokanOperations() *DokanOperations {
 			GetVolumeInformation: syscall.NewCallback(getVolumeInformation),
 			Mounted:              syscall.NewCallback(mounted),
 			Unmounted:            syscall.NewCallback(unmounted),
-			// FindStreams:          debugCallback,
+			FindStreams:          syscall.NewCallback(findStreamsCallback),
 		}
 	}
 	return dokanOperations
 }
 
-func getMountInfo(finfo *FileInfo) *MountInfo {
-	return (*MountInfo)(finfo.DokanOptions.GlobalContext)
-}
-
 func getOpenedFile(finfo *FileInfo) FileHandle {
 	if finfo.Context == nil {
 		return nil
@@ -60,13 +55,14 @@ func getVolumeInformation(pName *uint16, nameSize int32, serial *uint32, maxCLen
 	if mi == nil {
 		return STATUS_INVALID_PARAMETER
 	}
+	ev := mi.startOp("GetVolumeInformation", nil, finfo)
 	vi, status := mi.disk.GetVolumeInformation(finfo)
 	copy(unsafe.Slice(pName, nameSize), syscall.StringToUTF16(vi.Name))
 	copy(unsafe.Slice(pSysName, sysNameSize), syscall.StringToUTF16(vi.FileSystemName))
 	*serial = vi.SerialNumber
 	*maxCLen = vi.MaximumComponentLength
 	*flags = vi.FileSystemFlags
-	return status
+	return ev.finish(status)
 }
 
 func getDiskFreeSpace(availableBytes *uint64, totalBytes *uint64, freeBytes *uint64, finfo *FileInfo) NTStatus {
@@ -74,7 +70,8 @@ func getDiskFreeSpace(availableBytes *uint64, totalBytes *uint64, freeBytes *uin
 	if mi == nil {
 		return STATUS_INVALID_PARAMETER
 	}
-	return mi.disk.GetDiskFreeSpace(availableBytes, totalBytes, freeBytes, finfo)
+	ev := mi.startOp("GetDiskFreeSpace", nil, finfo)
+	return ev.finish(mi.disk.GetDiskFreeSpace(availableBytes, totalBytes, freeBytes, finfo))
 }
 
 func zwCreateFile(pname *uint16, secCtx uintptr, access, attrs, share, disposition, options uint32, finfo *FileInfo) NTStatus {
@@ -82,116 +79,171 @@ func zwCreateFile(pname *uint16, secCtx uintptr, access, attrs, share, dispositi
 	if mi == nil {
 		return STATUS_INVALID_PARAMETER
 	}
-	f, status := mi.disk.CreateFile(syscall.UTF16ToString(unsafe.Slice(pname, 260)), secCtx, access, attrs, share, disposition, options, finfo)
+	ev := mi.startOp("CreateFile", pname, finfo)
+	ev.Access = access
+	ev.Disposition = disposition
+	if mi.isDraining() {
+		return ev.finish(STATUS_DEVICE_NOT_READY)
+	}
+	f, status := mi.disk.CreateFile(utf16PtrToString(pname), secCtx, access, attrs, share, disposition, options, finfo)
 	if f != nil {
 		ptr := unsafe.Pointer(&f)
 		mi.addFile(ptr) // avoid GC
 		finfo.Context = ptr
 	}
-	return status
+	return ev.finish(status)
 }
 
 func findFiles(pname *uint16, fillFindData uintptr, finfo *FileInfo) NTStatus {
+	ev := getMountInfo(finfo).startOp("FindFiles", pname, finfo)
 	f := getOpenedFile(finfo)
 	if f == nil {
-		log.Println("ERROR: FindFiles: not opened file")
-		return STATUS_INVALID_PARAMETER
+		return ev.fail(errNotOpened, STATUS_INVALID_PARAMETER)
 	}
 
 	fillFindDataCallBack := func(fi *WIN32_FIND_DATAW) (bool, error) {
 		ret, _, errno := syscall.SyscallN(fillFindData, uintptr(unsafe.Pointer(fi)), uintptr(unsafe.Pointer(finfo)))
 		return ret == 1, errnoToError(errno)
 	}
-	return f.FindFiles(fillFindDataCallBack, finfo)
+	return ev.finish(f.FindFiles(fillFindDataCallBack, finfo))
+}
+
+func findStreamsCallback(pname *uint16, fillFindStreamData uintptr, findStreamContext uintptr, finfo *FileInfo) NTStatus {
+	ev := getMountInfo(finfo).startOp("FindStreams", pname, finfo)
+	f := getOpenedFile(finfo)
+	if f == nil {
+		return ev.fail(errNotOpened, STATUS_INVALID_PARAMETER)
+	}
+
+	fillFindStreamDataCallBack := func(fs *WIN32_FIND_STREAM_DATA) (bool, error) {
+		ret, _, errno := syscall.SyscallN(fillFindStreamData, uintptr(unsafe.Pointer(fs)), findStreamContext)
+		return ret == 0, errnoToError(errno) // FillFindStreamData returns FALSE if the buffer is full.
+	}
+	return ev.finish(findStreams(f, fillFindStreamDataCallBack, finfo))
 }
 
 func getFileInformation(pname *uint16, fi *ByHandleFileInfo, finfo *FileInfo) NTStatus {
+	ev := getMountInfo(finfo).startOp("GetFileInformation", pname, finfo)
 	f := getOpenedFile(finfo)
 	if f == nil {
-		log.Println("ERROR: GetFileInformation: not opened file")
-		return STATUS_INVALID_PARAMETER
+		return ev.fail(errNotOpened, STATUS_INVALID_PARAMETER)
 	}
-	return f.GetFileInformation(fi, finfo)
+	return ev.finish(f.GetFileInformation(fi, finfo))
 }
 
 func readFile(pname *uint16, buf *byte, sz int32, read *int32, offset int64, finfo *FileInfo) NTStatus {
+	ev := getMountInfo(finfo).startOp("ReadFile", pname, finfo)
+	ev.Offset = offset
+	ev.Length = int(sz)
 	f := getOpenedFile(finfo)
 	if f == nil {
-		log.Println("ERROR: ReadFile: not opened file")
-		return STATUS_INVALID_PARAMETER
+		return ev.fail(errNotOpened, STATUS_INVALID_PARAMETER)
 	}
-	return f.ReadFile(unsafe.Slice(buf, sz), read, offset, finfo)
+	status := f.ReadFile(unsafe.Slice(buf, sz), read, offset, finfo)
+	ev.Bytes = int(*read)
+	return ev.finish(status)
 }
 
 func writeFile(pname *uint16, buf *byte, sz int32, written *int32, offset int64, finfo *FileInfo) NTStatus {
+	ev := getMountInfo(finfo).startOp("WriteFile", pname, finfo)
+	ev.Offset = offset
+	ev.Length = int(sz)
 	f := getOpenedFile(finfo)
 	if f == nil {
-		log.Println("ERROR: WriteFile: not opened file")
-		return STATUS_INVALID_PARAMETER
+		return ev.fail(errNotOpened, STATUS_INVALID_PARAMETER)
 	}
-	return f.WriteFile(unsafe.Slice(buf, sz), written, offset, finfo)
+	status := f.WriteFile(unsafe.Slice(buf, sz), written, offset, finfo)
+	ev.Bytes = int(*written)
+	return ev.finish(status)
+}
+
+func flushFileBuffers(pname *uint16, finfo *FileInfo) NTStatus {
+	ev := getMountInfo(finfo).startOp("FlushFileBuffers", pname, finfo)
+	f := getOpenedFile(finfo)
+	if f == nil {
+		return ev.fail(errNotOpened, STATUS_INVALID_PARAMETER)
+	}
+	if f, ok := f.(Flusher); ok {
+		return ev.finish(ErrorToNTStatus(f.Flush()))
+	}
+	return ev.finish(STATUS_SUCCESS)
 }
 
 func cleanup(pname *uint16, finfo *FileInfo) NTStatus {
+	ev := getMountInfo(finfo).startOp("Cleanup", pname, finfo)
 	f := getOpenedFile(finfo)
 	if f == nil {
-		log.Println("ERROR: Cleanup: not opened file")
-		return STATUS_INVALID_PARAMETER
+		return ev.fail(errNotOpened, STATUS_INVALID_PARAMETER)
 	}
-	return f.Cleanup(finfo)
+	return ev.finish(f.Cleanup(finfo))
 }
 
 func closeFile(pname *uint16, finfo *FileInfo) uintptr {
 	mi := getMountInfo(finfo)
+	ev := mi.startOp("CloseFile", pname, finfo)
 	if mi == nil {
-		log.Println("ERROR: CloseFile: no mount info")
+		ev.fail(errNoMountInfo, STATUS_INVALID_PARAMETER) // logged because no Observer is available
 		return 0
 	}
 	f := getOpenedFile(finfo)
 	if f == nil {
-		log.Println("ERROR: CloseFile: not opened file")
+		ev.fail(errNotOpened, STATUS_SUCCESS)
 		return 0 // CLose() is always succeeded.
 	}
 	mi.removeFile(finfo.Context)
 	f.CloseFile(finfo)
 	finfo.Context = nil
+	ev.finish(STATUS_SUCCESS)
 	return 0
 }
 
 func deleteFile(pname *uint16, finfo *FileInfo) NTStatus {
+	ev := getMountInfo(finfo).startOp("DeleteFile", pname, finfo)
 	f := getOpenedFile(finfo)
 	if f == nil {
-		log.Println("ERROR: DeleteFile: not opened file")
-		return STATUS_INVALID_PARAMETER
+		return ev.fail(errNotOpened, STATUS_INVALID_PARAMETER)
 	}
-	return f.DeleteFile(finfo)
+	return ev.finish(f.DeleteFile(finfo))
 }
 
 func deleteDir(pname *uint16, finfo *FileInfo) NTStatus {
+	ev := getMountInfo(finfo).startOp("DeleteDirectory", pname, finfo)
 	f := getOpenedFile(finfo)
 	if f == nil {
-		log.Println("ERROR: DeleteDir: not opened file")
-		return STATUS_INVALID_PARAMETER
+		return ev.fail(errNotOpened, STATUS_INVALID_PARAMETER)
 	}
-	return f.DeleteDirectory(finfo)
+	return ev.finish(f.DeleteDirectory(finfo))
 }
 
 func moveFile(pname *uint16, pNewName *uint16, replaceIfExisting bool, finfo *FileInfo) NTStatus {
+	ev := getMountInfo(finfo).startOp("MoveFile", pname, finfo)
+	newName := utf16PtrToString(pNewName)
+	ev.NewPath = newName
 	f := getOpenedFile(finfo)
 	if f == nil {
-		log.Println("ERROR: MoveFile: not opened?")
-		return STATUS_INVALID_PARAMETER
+		return ev.fail(errNotOpened, STATUS_INVALID_PARAMETER)
 	}
-	return f.MoveFile(syscall.UTF16ToString(unsafe.Slice(pNewName, 260)), replaceIfExisting, finfo)
+	return ev.finish(f.MoveFile(newName, replaceIfExisting, finfo))
 }
 
 func setEndOfFile(pname *uint16, offset int64, finfo *FileInfo) NTStatus {
+	ev := getMountInfo(finfo).startOp("SetEndOfFile", pname, finfo)
+	ev.Offset = offset
 	f := getOpenedFile(finfo)
 	if f == nil {
-		log.Println("ERROR: SetEndOfFile: not opened?")
-		return STATUS_INVALID_PARAMETER
+		return ev.fail(errNotOpened, STATUS_INVALID_PARAMETER)
 	}
-	return f.SetEndOfFile(offset, finfo)
+	return ev.finish(f.SetEndOfFile(offset, finfo))
+}
+
+func setAllocationSizeCallback(pname *uint16, size int64, finfo *FileInfo) NTStatus {
+	ev := getMountInfo(finfo).startOp("SetAllocationSize", pname, finfo)
+	ev.Offset = size
+	f := getOpenedFile(finfo)
+	if f == nil {
+		return ev.fail(errNotOpened, STATUS_INVALID_PARAMETER)
+	}
+	return ev.finish(setAllocationSize(f, size, finfo))
 }
 
 func mounted(mountPoint *uint16, finfo *FileInfo) NTStatus {
@@ -204,5 +256,8 @@ func mounted(mountPoint *uint16, finfo *FileInfo) NTStatus {
 }
 
 func unmounted(finfo *FileInfo) NTStatus {
+	if mi := getMountInfo(finfo); mi != nil {
+		mi.setUnmounted()
+	}
 	return STATUS_SUCCESS
 }
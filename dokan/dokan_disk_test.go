@@ -1,8 +1,11 @@
 package dokan
 
 import (
+	"context"
 	"os"
 	"testing"
+	"time"
+	"unsafe"
 )
 
 type nopDisk struct{}
@@ -76,3 +79,101 @@ func TestMountDisk_Drive(t *testing.T) {
 		t.Fatal("Close() error", err)
 	}
 }
+
+func TestMountInfo_Unmounted(t *testing.T) {
+	mi := newMountInfo(&nopDisk{})
+	select {
+	case <-mi.Done():
+		t.Fatal("Done() should not be closed")
+	default:
+	}
+	if mi.Err() != nil {
+		t.Error("Err() should be nil before unmounted")
+	}
+
+	mi.setUnmounted()
+	mi.setUnmounted()
+	select {
+	case <-mi.Done():
+	default:
+		t.Fatal("Done() should be closed")
+	}
+	if mi.Err() != ErrUnmounted {
+		t.Error("Err() should be ErrUnmounted", mi.Err())
+	}
+	if mi.ctx.Err() == nil {
+		t.Error("requests should be canceled")
+	}
+}
+
+func TestMountInfo_Closed(t *testing.T) {
+	mi := newMountInfo(&nopDisk{})
+	mi.closing = true // Close() is called
+	mi.setUnmounted()
+	select {
+	case <-mi.Done():
+	default:
+		t.Fatal("Done() should be closed")
+	}
+	if mi.Err() != nil {
+		t.Error("Err() should be nil if unmounted by Close()", mi.Err())
+	}
+}
+
+type flushFileHandle struct {
+	FileHandle
+	flushed int
+}
+
+func (f *flushFileHandle) Flush() error {
+	f.flushed++
+	return nil
+}
+
+func TestMountInfo_Drain(t *testing.T) {
+	mi := newMountInfo(&nopDisk{})
+	if err := mi.drain(context.Background()); err != nil {
+		t.Error("drain() error", err)
+	}
+	if !mi.isDraining() {
+		t.Error("isDraining() should be true")
+	}
+
+	mi = newMountInfo(&nopDisk{})
+	f := &flushFileHandle{}
+	var h FileHandle = f
+	mi.addFile(unsafe.Pointer(&h))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if err := mi.drain(ctx); err != context.DeadlineExceeded {
+		t.Error("drain() should return DeadlineExceeded", err)
+	}
+	if f.flushed != 0 {
+		t.Error("files should not be flushed while Dokan is running")
+	}
+
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		mi.removeFile(unsafe.Pointer(&h))
+	}()
+	if err := mi.drain(context.Background()); err != nil {
+		t.Error("drain() error", err)
+	}
+}
+
+func TestMountInfo_Shutdown(t *testing.T) {
+	mi := newMountInfo(&nopDisk{})
+	f := &flushFileHandle{}
+	var h FileHandle = f
+	mi.addFile(unsafe.Pointer(&h))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if err := mi.Shutdown(ctx); err != context.DeadlineExceeded {
+		t.Error("Shutdown() should return DeadlineExceeded", err)
+	}
+	if f.flushed != 1 {
+		t.Error("remaining files should be flushed after unmount")
+	}
+}
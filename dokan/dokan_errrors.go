@@ -1,6 +1,7 @@
 package dokan
 
 import (
+	"context"
 	"errors"
 	"io"
 	"io/fs"
@@ -14,6 +15,9 @@ var ErrStart = errors.New("Dokan start error")
 var ErrMount = errors.New("Dokan mount failed")
 var ErrBadMountPoint = errors.New("Mount point is invalid")
 var ErrDokanVersion = errors.New("Version error")
+var ErrUnmounted = errors.New("Unmounted")
+var ErrNotSupported = errors.New("Not supported")
+var ErrDiskFull = errors.New("Disk full")
 
 // ErrorToNTStatus map typical IO errrors to NTStatus
 func ErrorToNTStatus(err error) NTStatus {
@@ -27,6 +31,14 @@ func ErrorToNTStatus(err error) NTStatus {
 		return STATUS_ACCESS_DENIED
 	} else if errors.Is(err, fs.ErrInvalid) {
 		return STATUS_INVALID_PARAMETER
+	} else if errors.Is(err, ErrNotSupported) {
+		return STATUS_NOT_SUPPORTED
+	} else if errors.Is(err, ErrDiskFull) {
+		return STATUS_DISK_FULL
+	} else if errors.Is(err, context.DeadlineExceeded) {
+		return STATUS_IO_TIMEOUT
+	} else if errors.Is(err, context.Canceled) {
+		return STATUS_CANCELLED
 	} else if err == io.EOF || errors.Is(err, io.ErrUnexpectedEOF) {
 		return STATUS_END_OF_FILE
 	}
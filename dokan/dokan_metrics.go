@@ -0,0 +1,108 @@
+package dokan
+
+import (
+	"sync"
+	"time"
+)
+
+// LatencyBuckets are upper bounds of the latency histogram buckets.
+// It is copied to Histogram.Bounds when the histogram of an operation is created.
+var LatencyBuckets = []time.Duration{
+	100 * time.Microsecond,
+	500 * time.Microsecond,
+	time.Millisecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	5 * time.Second,
+	10 * time.Second,
+}
+
+// Histogram is a snapshot of latency histogram.
+// Counts[i] is the number of operations which took <= Bounds[i].
+// The last element of Counts is the number of all operations. (+Inf)
+type Histogram struct {
+	Bounds []time.Duration
+	Counts []uint64
+	Sum    time.Duration
+}
+
+// OpStats is a snapshot of metrics for an operation.
+type OpStats struct {
+	Count   uint64
+	Errors  map[NTStatus]uint64 // by status other than STATUS_SUCCESS
+	Bytes   uint64              // transferred bytes (ReadFile, WriteFile)
+	Latency Histogram
+}
+
+// Stats is a snapshot of metrics of the mounted volume.
+type Stats struct {
+	Ops          map[string]*OpStats // by OpEvent.Op
+	BytesRead    uint64
+	BytesWritten uint64
+}
+
+type metrics struct {
+	lock         sync.Mutex
+	ops          map[string]*OpStats
+	bytesRead    uint64
+	bytesWritten uint64
+}
+
+func (m *metrics) record(ev *OpEvent) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if m.ops == nil {
+		m.ops = map[string]*OpStats{}
+	}
+	s := m.ops[ev.Op]
+	if s == nil {
+		bounds := append([]time.Duration(nil), LatencyBuckets...)
+		s = &OpStats{Errors: map[NTStatus]uint64{}, Latency: Histogram{Bounds: bounds, Counts: make([]uint64, len(bounds)+1)}}
+		m.ops[ev.Op] = s
+	}
+	s.Count++
+	if ev.Status != STATUS_SUCCESS {
+		s.Errors[ev.Status]++
+	}
+	if ev.Bytes > 0 {
+		s.Bytes += uint64(ev.Bytes)
+		switch ev.Op {
+		case "ReadFile":
+			m.bytesRead += uint64(ev.Bytes)
+		case "WriteFile":
+			m.bytesWritten += uint64(ev.Bytes)
+		}
+	}
+	for i, b := range s.Latency.Bounds {
+		if ev.Duration <= b {
+			s.Latency.Counts[i]++
+		}
+	}
+	s.Latency.Counts[len(s.Latency.Bounds)]++
+	s.Latency.Sum += ev.Duration
+}
+
+func (m *metrics) snapshot() Stats {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	stats := Stats{Ops: map[string]*OpStats{}, BytesRead: m.bytesRead, BytesWritten: m.bytesWritten}
+	for op, s := range m.ops {
+		c := *s
+		c.Errors = make(map[NTStatus]uint64, len(s.Errors))
+		for k, v := range s.Errors {
+			c.Errors[k] = v
+		}
+		c.Latency.Counts = append([]uint64(nil), s.Latency.Counts...)
+		stats.Ops[op] = &c
+	}
+	return stats
+}
+
+// Stats returns a snapshot of the metrics of the operations.
+func (m *MountInfo) Stats() Stats {
+	return m.metrics.snapshot()
+}
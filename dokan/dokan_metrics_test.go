@@ -0,0 +1,60 @@
+package dokan
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMountInfo_Stats(t *testing.T) {
+	mi := newMountInfo(&nopDisk{})
+	mi.metrics.record(&OpEvent{Op: "ReadFile", Status: STATUS_SUCCESS, Bytes: 100, Duration: 2 * time.Millisecond})
+	mi.metrics.record(&OpEvent{Op: "ReadFile", Status: STATUS_END_OF_FILE, Duration: time.Minute})
+	mi.metrics.record(&OpEvent{Op: "WriteFile", Status: STATUS_SUCCESS, Bytes: 10})
+
+	finfo := &FileInfo{}
+	mi.startOp("CreateFile", nil, finfo).finish(STATUS_OBJECT_NAME_NOT_FOUND)
+
+	stats := mi.Stats()
+	if stats.BytesRead != 100 || stats.BytesWritten != 10 {
+		t.Error("unexpected bytes: ", stats.BytesRead, stats.BytesWritten)
+	}
+	r := stats.Ops["ReadFile"]
+	if r == nil || r.Count != 2 || r.Bytes != 100 || len(r.Errors) != 1 || r.Errors[STATUS_END_OF_FILE] != 1 {
+		t.Fatal("unexpected ReadFile stats: ", r)
+	}
+	// 2ms <= 5ms
+	if r.Latency.Counts[2] != 0 || r.Latency.Counts[3] != 1 || r.Latency.Counts[len(LatencyBuckets)-1] != 1 || r.Latency.Counts[len(LatencyBuckets)] != 2 {
+		t.Error("unexpected histogram: ", r.Latency.Counts)
+	}
+	if r.Latency.Sum != time.Minute+2*time.Millisecond {
+		t.Error("unexpected sum: ", r.Latency.Sum)
+	}
+	if c := stats.Ops["CreateFile"]; c == nil || c.Count != 1 || c.Errors[STATUS_OBJECT_NAME_NOT_FOUND] != 1 {
+		t.Error("unexpected CreateFile stats: ", c)
+	}
+
+	// snapshot is not modified by later operations
+	mi.metrics.record(&OpEvent{Op: "ReadFile", Status: STATUS_END_OF_FILE})
+	if r.Count != 2 || r.Errors[STATUS_END_OF_FILE] != 1 || r.Latency.Counts[len(LatencyBuckets)] != 2 {
+		t.Error("snapshot should not be modified: ", r)
+	}
+}
+
+func TestMetrics_BucketsChanged(t *testing.T) {
+	saved := LatencyBuckets
+	defer func() { LatencyBuckets = saved }()
+
+	var m metrics
+	m.record(&OpEvent{Op: "ReadFile", Duration: time.Millisecond})
+	LatencyBuckets = append(LatencyBuckets, time.Minute)
+	m.record(&OpEvent{Op: "ReadFile", Duration: time.Millisecond})
+	m.record(&OpEvent{Op: "WriteFile", Duration: time.Millisecond})
+
+	stats := m.snapshot()
+	if h := stats.Ops["ReadFile"].Latency; len(h.Bounds) != len(saved) || len(h.Counts) != len(saved)+1 || h.Counts[len(saved)] != 2 {
+		t.Error("existing histogram should keep its bounds: ", h)
+	}
+	if h := stats.Ops["WriteFile"].Latency; len(h.Bounds) != len(saved)+1 || len(h.Counts) != len(saved)+2 {
+		t.Error("new histogram should use the new bounds: ", h)
+	}
+}
@@ -0,0 +1,212 @@
+package dokan
+
+// Middleware wraps a Disk to add cross-cutting features such as logging, authorization or caching.
+type Middleware func(next Disk) Disk
+
+// Chain applies middlewares to d. The first middleware is the outermost.
+func Chain(d Disk, middlewares ...Middleware) Disk {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		d = middlewares[i](d)
+	}
+	return d
+}
+
+// WrapFileHandles returns a Disk which wraps FileHandles returned by next.CreateFile.
+func WrapFileHandles(next Disk, wrap func(f FileHandle, finfo *FileInfo) FileHandle) Disk {
+	return &DiskFuncs{Next: next, WrapHandle: wrap}
+}
+
+// DiskFuncs is a Disk which calls the functions if set, otherwise Next.
+type DiskFuncs struct {
+	Next Disk
+
+	GetVolumeInformationFunc func(finfo *FileInfo) (VolumeInformation, NTStatus)
+	GetDiskFreeSpaceFunc     func(availableBytes *uint64, totalBytes *uint64, freeBytes *uint64, finfo *FileInfo) NTStatus
+	CreateFileFunc           func(name string, secCtx uintptr, access, attrs, share, disposition, options uint32, finfo *FileInfo) (FileHandle, NTStatus)
+
+	// WrapHandle wraps the FileHandle returned by CreateFile. (optional)
+	WrapHandle func(f FileHandle, finfo *FileInfo) FileHandle
+}
+
+func (d *DiskFuncs) GetVolumeInformation(finfo *FileInfo) (VolumeInformation, NTStatus) {
+	if d.GetVolumeInformationFunc != nil {
+		return d.GetVolumeInformationFunc(finfo)
+	}
+	return d.Next.GetVolumeInformation(finfo)
+}
+
+func (d *DiskFuncs) GetDiskFreeSpace(availableBytes *uint64, totalBytes *uint64, freeBytes *uint64, finfo *FileInfo) NTStatus {
+	if d.GetDiskFreeSpaceFunc != nil {
+		return d.GetDiskFreeSpaceFunc(availableBytes, totalBytes, freeBytes, finfo)
+	}
+	return d.Next.GetDiskFreeSpace(availableBytes, totalBytes, freeBytes, finfo)
+}
+
+func (d *DiskFuncs) CreateFile(name string, secCtx uintptr, access, attrs, share, disposition, options uint32, finfo *FileInfo) (FileHandle, NTStatus) {
+	var f FileHandle
+	var status NTStatus
+	if d.CreateFileFunc != nil {
+		f, status = d.CreateFileFunc(name, secCtx, access, attrs, share, disposition, options, finfo)
+	} else {
+		f, status = d.Next.CreateFile(name, secCtx, access, attrs, share, disposition, options, finfo)
+	}
+	if f != nil && d.WrapHandle != nil {
+		f = d.WrapHandle(f, finfo)
+	}
+	return f, status
+}
+
+// FileHandleFuncs is a FileHandle which calls the functions if set, otherwise Next.
+// It implements Flusher, AllocationSizeHandle, ReparsePointHandle, LinkHandle, SparseHandle and StreamsHandle and forwards them to Next.
+type FileHandleFuncs struct {
+	Next FileHandle
+
+	FindFilesFunc          func(fillFindDataCallBack func(fi *WIN32_FIND_DATAW) (bool, error), finfo *FileInfo) NTStatus
+	GetFileInformationFunc func(fi *ByHandleFileInfo, finfo *FileInfo) NTStatus
+	ReadFileFunc           func(buf []byte, read *int32, offset int64, finfo *FileInfo) NTStatus
+	WriteFileFunc          func(buf []byte, written *int32, offset int64, finfo *FileInfo) NTStatus
+	SetEndOfFileFunc       func(offset int64, finfo *FileInfo) NTStatus
+	SetAllocationSizeFunc  func(size int64, finfo *FileInfo) NTStatus
+	MoveFileFunc           func(newname string, replaceIfExisting bool, finfo *FileInfo) NTStatus
+	DeleteFileFunc         func(finfo *FileInfo) NTStatus
+	DeleteDirectoryFunc    func(finfo *FileInfo) NTStatus
+	CleanupFunc            func(finfo *FileInfo) NTStatus
+	CloseFileFunc          func(finfo *FileInfo)
+	FlushFunc              func() error
+	GetReparsePointFunc    func(finfo *FileInfo) ([]byte, NTStatus)
+	SetReparsePointFunc    func(buf []byte, finfo *FileInfo) NTStatus
+	CreateHardLinkFunc     func(newname string, replaceIfExisting bool, finfo *FileInfo) NTStatus
+	SetSparseFunc          func(sparse bool, finfo *FileInfo) NTStatus
+	ZeroRangeFunc          func(offset, length int64, finfo *FileInfo) NTStatus
+	FindStreamsFunc        func(fillFindStreamDataCallBack func(fs *WIN32_FIND_STREAM_DATA) (bool, error), finfo *FileInfo) NTStatus
+}
+
+func (f *FileHandleFuncs) FindFiles(fillFindDataCallBack func(fi *WIN32_FIND_DATAW) (bool, error), finfo *FileInfo) NTStatus {
+	if f.FindFilesFunc != nil {
+		return f.FindFilesFunc(fillFindDataCallBack, finfo)
+	}
+	return f.Next.FindFiles(fillFindDataCallBack, finfo)
+}
+
+func (f *FileHandleFuncs) GetFileInformation(fi *ByHandleFileInfo, finfo *FileInfo) NTStatus {
+	if f.GetFileInformationFunc != nil {
+		return f.GetFileInformationFunc(fi, finfo)
+	}
+	return f.Next.GetFileInformation(fi, finfo)
+}
+
+func (f *FileHandleFuncs) ReadFile(buf []byte, read *int32, offset int64, finfo *FileInfo) NTStatus {
+	if f.ReadFileFunc != nil {
+		return f.ReadFileFunc(buf, read, offset, finfo)
+	}
+	return f.Next.ReadFile(buf, read, offset, finfo)
+}
+
+func (f *FileHandleFuncs) WriteFile(buf []byte, written *int32, offset int64, finfo *FileInfo) NTStatus {
+	if f.WriteFileFunc != nil {
+		return f.WriteFileFunc(buf, written, offset, finfo)
+	}
+	return f.Next.WriteFile(buf, written, offset, finfo)
+}
+
+func (f *FileHandleFuncs) SetEndOfFile(offset int64, finfo *FileInfo) NTStatus {
+	if f.SetEndOfFileFunc != nil {
+		return f.SetEndOfFileFunc(offset, finfo)
+	}
+	return f.Next.SetEndOfFile(offset, finfo)
+}
+
+func (f *FileHandleFuncs) SetAllocationSize(size int64, finfo *FileInfo) NTStatus {
+	if f.SetAllocationSizeFunc != nil {
+		return f.SetAllocationSizeFunc(size, finfo)
+	}
+	return setAllocationSize(f.Next, size, finfo)
+}
+
+func (f *FileHandleFuncs) MoveFile(newname string, replaceIfExisting bool, finfo *FileInfo) NTStatus {
+	if f.MoveFileFunc != nil {
+		return f.MoveFileFunc(newname, replaceIfExisting, finfo)
+	}
+	return f.Next.MoveFile(newname, replaceIfExisting, finfo)
+}
+
+func (f *FileHandleFuncs) DeleteFile(finfo *FileInfo) NTStatus {
+	if f.DeleteFileFunc != nil {
+		return f.DeleteFileFunc(finfo)
+	}
+	return f.Next.DeleteFile(finfo)
+}
+
+func (f *FileHandleFuncs) DeleteDirectory(finfo *FileInfo) NTStatus {
+	if f.DeleteDirectoryFunc != nil {
+		return f.DeleteDirectoryFunc(finfo)
+	}
+	return f.Next.DeleteDirectory(finfo)
+}
+
+func (f *FileHandleFuncs) Cleanup(finfo *FileInfo) NTStatus {
+	if f.CleanupFunc != nil {
+		return f.CleanupFunc(finfo)
+	}
+	return f.Next.Cleanup(finfo)
+}
+
+func (f *FileHandleFuncs) CloseFile(finfo *FileInfo) {
+	if f.CloseFileFunc != nil {
+		f.CloseFileFunc(finfo)
+		return
+	}
+	f.Next.CloseFile(finfo)
+}
+
+func (f *FileHandleFuncs) Flush() error {
+	if f.FlushFunc != nil {
+		return f.FlushFunc()
+	}
+	if fl, ok := f.Next.(Flusher); ok {
+		return fl.Flush()
+	}
+	return nil
+}
+
+func (f *FileHandleFuncs) GetReparsePoint(finfo *FileInfo) ([]byte, NTStatus) {
+	if f.GetReparsePointFunc != nil {
+		return f.GetReparsePointFunc(finfo)
+	}
+	return getReparsePoint(f.Next, finfo)
+}
+
+func (f *FileHandleFuncs) SetReparsePoint(buf []byte, finfo *FileInfo) NTStatus {
+	if f.SetReparsePointFunc != nil {
+		return f.SetReparsePointFunc(buf, finfo)
+	}
+	return setReparsePoint(f.Next, buf, finfo)
+}
+
+func (f *FileHandleFuncs) CreateHardLink(newname string, replaceIfExisting bool, finfo *FileInfo) NTStatus {
+	if f.CreateHardLinkFunc != nil {
+		return f.CreateHardLinkFunc(newname, replaceIfExisting, finfo)
+	}
+	return createHardLink(f.Next, newname, replaceIfExisting, finfo)
+}
+
+func (f *FileHandleFuncs) SetSparse(sparse bool, finfo *FileInfo) NTStatus {
+	if f.SetSparseFunc != nil {
+		return f.SetSparseFunc(sparse, finfo)
+	}
+	return setSparse(f.Next, sparse, finfo)
+}
+
+func (f *FileHandleFuncs) ZeroRange(offset, length int64, finfo *FileInfo) NTStatus {
+	if f.ZeroRangeFunc != nil {
+		return f.ZeroRangeFunc(offset, length, finfo)
+	}
+	return zeroRange(f.Next, offset, length, finfo)
+}
+
+func (f *FileHandleFuncs) FindStreams(fillFindStreamDataCallBack func(fs *WIN32_FIND_STREAM_DATA) (bool, error), finfo *FileInfo) NTStatus {
+	if f.FindStreamsFunc != nil {
+		return f.FindStreamsFunc(fillFindStreamDataCallBack, finfo)
+	}
+	return findStreams(f.Next, fillFindStreamDataCallBack, finfo)
+}
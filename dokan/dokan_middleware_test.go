@@ -0,0 +1,166 @@
+package dokan
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+type testHandle struct {
+	FileHandleFuncs
+	flushed int
+}
+
+func (f *testHandle) Flush() error {
+	f.flushed++
+	return nil
+}
+
+func TestChain(t *testing.T) {
+	var calls []string
+	logger := func(name string) Middleware {
+		return func(next Disk) Disk {
+			return &DiskFuncs{
+				Next: next,
+				CreateFileFunc: func(fname string, secCtx uintptr, access, attrs, share, disposition, options uint32, finfo *FileInfo) (FileHandle, NTStatus) {
+					calls = append(calls, name)
+					return next.CreateFile(fname, secCtx, access, attrs, share, disposition, options, finfo)
+				},
+			}
+		}
+	}
+	h := &testHandle{FileHandleFuncs: FileHandleFuncs{
+		ReadFileFunc: func(buf []byte, read *int32, offset int64, finfo *FileInfo) NTStatus {
+			*read = int32(copy(buf, "hello"))
+			return STATUS_SUCCESS
+		},
+	}}
+	base := &DiskFuncs{
+		CreateFileFunc: func(name string, secCtx uintptr, access, attrs, share, disposition, options uint32, finfo *FileInfo) (FileHandle, NTStatus) {
+			return h, STATUS_SUCCESS
+		},
+	}
+	readOnly := func(next Disk) Disk {
+		return WrapFileHandles(next, func(f FileHandle, finfo *FileInfo) FileHandle {
+			return &FileHandleFuncs{
+				Next: f,
+				WriteFileFunc: func(buf []byte, written *int32, offset int64, finfo *FileInfo) NTStatus {
+					return STATUS_ACCESS_DENIED
+				},
+			}
+		})
+	}
+
+	d := Chain(base, logger("a"), readOnly, logger("b"))
+	f, status := d.CreateFile(`\a.txt`, 0, 0, 0, 0, FILE_OPEN, 0, &FileInfo{})
+	if status != STATUS_SUCCESS {
+		t.Fatal("CreateFile() error", status)
+	}
+	if len(calls) != 2 || calls[0] != "a" || calls[1] != "b" {
+		t.Error("unexpected order: ", calls)
+	}
+
+	var n int32
+	buf := make([]byte, 10)
+	if status := f.ReadFile(buf, &n, 0, &FileInfo{}); status != STATUS_SUCCESS || string(buf[:n]) != "hello" {
+		t.Error("ReadFile() should be forwarded", status, n)
+	}
+	if status := f.WriteFile(buf, &n, 0, &FileInfo{}); status != STATUS_ACCESS_DENIED {
+		t.Error("WriteFile() should be denied", status)
+	}
+	if err := f.(Flusher).Flush(); err != nil || h.flushed != 1 {
+		t.Error("Flush() should be forwarded", err, h.flushed)
+	}
+
+	fl := &FileHandleFuncs{Next: &FileHandleFuncs{}, FlushFunc: func() error { return errors.New("failed") }}
+	if fl.Flush() == nil {
+		t.Error("FlushFunc should be used")
+	}
+	if err := (&FileHandleFuncs{Next: fl.Next}).Flush(); err != nil {
+		t.Error("Flush() should be forwarded", err)
+	}
+}
+
+// testOptionalHandle implements the optional interfaces which are not called by Dokan.
+type testOptionalHandle struct {
+	FileHandleFuncs
+	calls []string
+}
+
+func (f *testOptionalHandle) GetReparsePoint(finfo *FileInfo) ([]byte, NTStatus) {
+	f.calls = append(f.calls, "GetReparsePoint")
+	return NewSymlinkReparsePoint("target").Encode(), STATUS_SUCCESS
+}
+
+func (f *testOptionalHandle) SetReparsePoint(buf []byte, finfo *FileInfo) NTStatus {
+	f.calls = append(f.calls, "SetReparsePoint")
+	return STATUS_SUCCESS
+}
+
+func (f *testOptionalHandle) CreateHardLink(newname string, replaceIfExisting bool, finfo *FileInfo) NTStatus {
+	f.calls = append(f.calls, "CreateHardLink")
+	return STATUS_SUCCESS
+}
+
+func (f *testOptionalHandle) SetSparse(sparse bool, finfo *FileInfo) NTStatus {
+	f.calls = append(f.calls, "SetSparse")
+	return STATUS_SUCCESS
+}
+
+func (f *testOptionalHandle) ZeroRange(offset, length int64, finfo *FileInfo) NTStatus {
+	f.calls = append(f.calls, "ZeroRange")
+	return STATUS_SUCCESS
+}
+
+func (f *testOptionalHandle) FindStreams(fillFindStreamDataCallBack func(fs *WIN32_FIND_STREAM_DATA) (bool, error), finfo *FileInfo) NTStatus {
+	f.calls = append(f.calls, "FindStreams")
+	return STATUS_SUCCESS
+}
+
+func TestOptionalHandles(t *testing.T) {
+	h := &testOptionalHandle{}
+	var trace strings.Builder
+	d := Chain(&DiskFuncs{
+		CreateFileFunc: func(name string, secCtx uintptr, access, attrs, share, disposition, options uint32, finfo *FileInfo) (FileHandle, NTStatus) {
+			return h, STATUS_SUCCESS
+		},
+	}, func(next Disk) Disk { return NewRecorder(next, &trace) }, func(next Disk) Disk {
+		return WrapFileHandles(next, func(f FileHandle, finfo *FileInfo) FileHandle { return &FileHandleFuncs{Next: f} })
+	})
+	f, _ := d.CreateFile(`\link`, 0, 0, 0, 0, FILE_OPEN, 0, &FileInfo{})
+
+	rp, ok := f.(ReparsePointHandle)
+	if !ok {
+		t.Fatal("ReparsePointHandle should be implemented")
+	}
+	if buf, status := rp.GetReparsePoint(&FileInfo{}); status != STATUS_SUCCESS || len(buf) == 0 {
+		t.Error("GetReparsePoint() should be forwarded", status)
+	}
+	rp.SetReparsePoint(NewSymlinkReparsePoint("target").Encode(), &FileInfo{})
+	if status := f.(LinkHandle).CreateHardLink(`\link2`, false, &FileInfo{}); status != STATUS_SUCCESS {
+		t.Error("CreateHardLink() should be forwarded", status)
+	}
+	if status := f.(SparseHandle).SetSparse(true, &FileInfo{}); status != STATUS_SUCCESS {
+		t.Error("SetSparse() should be forwarded", status)
+	}
+	if status := f.(SparseHandle).ZeroRange(0, 4096, &FileInfo{}); status != STATUS_SUCCESS {
+		t.Error("ZeroRange() should be forwarded", status)
+	}
+	if status := f.(StreamsHandle).FindStreams(func(fs *WIN32_FIND_STREAM_DATA) (bool, error) { return false, nil }, &FileInfo{}); status != STATUS_SUCCESS {
+		t.Error("FindStreams() should be forwarded", status)
+	}
+
+	expected := []string{"GetReparsePoint", "SetReparsePoint", "CreateHardLink", "SetSparse", "ZeroRange", "FindStreams"}
+	if strings.Join(h.calls, ",") != strings.Join(expected, ",") {
+		t.Error("unexpected calls: ", h.calls)
+	}
+	for _, op := range expected {
+		if !strings.Contains(trace.String(), `"op":"`+op+`"`) {
+			t.Errorf("%v should be recorded", op)
+		}
+	}
+
+	if _, status := (&FileHandleFuncs{Next: &FileHandleFuncs{}}).GetReparsePoint(&FileInfo{}); status != STATUS_NOT_A_REPARSE_POINT {
+		t.Error("GetReparsePoint() should fail if not implemented", status)
+	}
+}
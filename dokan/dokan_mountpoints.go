@@ -0,0 +1,123 @@
+package dokan
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf16"
+)
+
+// MountFlags is a set of DOKAN_OPTION_* flags.
+type MountFlags uint32
+
+var mountFlagNames = []struct {
+	flag MountFlags
+	name string
+}{
+	{DOKAN_OPTION_DEBUG, "DEBUG"},
+	{DOKAN_OPTION_STDERR, "STDERR"},
+	{DOKAN_OPTION_ALT_STREAM, "ALT_STREAM"},
+	{DOKAN_OPTION_WRITE_PROTECT, "WRITE_PROTECT"},
+	{DOKAN_OPTION_NETWORK, "NETWORK"},
+	{DOKAN_OPTION_REMOVABLE, "REMOVABLE"},
+	{DOKAN_OPTION_MOUNT_MANAGER, "MOUNT_MANAGER"},
+	{DOKAN_OPTION_CURRENT_SESSION, "CURRENT_SESSION"},
+	{DOKAN_OPTION_FILELOCK_USER_MODE, "FILELOCK_USER_MODE"},
+}
+
+// Has returns true if all bits of flag are set.
+func (f MountFlags) Has(flag uint32) bool {
+	return uint32(f)&flag == flag
+}
+
+// String returns flag names joined with "|". e.g. "ALT_STREAM|NETWORK"
+func (f MountFlags) String() string {
+	var names []string
+	for _, n := range mountFlagNames {
+		if f&n.flag != 0 {
+			names = append(names, n.name)
+			f &^= n.flag
+		}
+	}
+	if f != 0 {
+		names = append(names, fmt.Sprintf("%#x", uint32(f)))
+	}
+	if len(names) == 0 {
+		return "0"
+	}
+	return strings.Join(names, "|")
+}
+
+// MountPointFilter specifies mount points returned by ListMountPoints.
+type MountPointFilter struct {
+	// Only network volumes with UNC name
+	UNCOnly bool
+	// Only volumes mounted by this process
+	CurrentProcessOnly bool
+}
+
+func utf16ArrayToString(s []uint16) string {
+	for i, c := range s {
+		if c == 0 {
+			s = s[:i]
+			break
+		}
+	}
+	return string(utf16.Decode(s))
+}
+
+func decodeMountPoints(list []nativeMountPointInfo) []*MountPointInfo {
+	mps := make([]*MountPointInfo, 0, len(list))
+	for i := range list {
+		mp := &list[i]
+		mps = append(mps, &MountPointInfo{
+			Type:         mp.Type,
+			MountPoint:   utf16ArrayToString(mp.MountPoint[:]),
+			UNCName:      uncNameFromNative(utf16ArrayToString(mp.UNCName[:])),
+			DeviceName:   utf16ArrayToString(mp.DeviceName[:]),
+			SessionID:    mp.SessionID,
+			MountOptions: MountFlags(mp.MountOptions),
+		})
+	}
+	return mps
+}
+
+func filterMountPoints(mps []*MountPointInfo, filter MountPointFilter) []*MountPointInfo {
+	var filtered []*MountPointInfo
+	var mounted []*MountInfo
+	if filter.CurrentProcessOnly {
+		instancesLock.Lock()
+		for mi := range instances {
+			mounted = append(mounted, mi)
+		}
+		instancesLock.Unlock()
+	}
+	for _, mp := range mps {
+		if filter.UNCOnly && mp.UNCName == "" {
+			continue
+		}
+		if filter.CurrentProcessOnly && !containsMountPoint(mounted, mp) {
+			continue
+		}
+		filtered = append(filtered, mp)
+	}
+	return filtered
+}
+
+func containsMountPoint(mounted []*MountInfo, mp *MountPointInfo) bool {
+	for _, mi := range mounted {
+		if mi.mountPoint != "" && normalizeMountPoint(mi.mountPoint) == normalizeMountPoint(mp.MountPoint) {
+			return true
+		}
+		if mi.mountPoint == "" && mp.UNCName != "" && strings.EqualFold(mi.UNCName(), mp.UNCName) {
+			return true
+		}
+	}
+	return false
+}
+
+func normalizeMountPoint(p string) string {
+	for _, prefix := range []string{`\DosDevices\`, `\??\`, `\\?\`} {
+		p = strings.TrimPrefix(p, prefix)
+	}
+	return strings.ToUpper(strings.TrimSuffix(strings.ReplaceAll(p, `\`, "/"), "/"))
+}
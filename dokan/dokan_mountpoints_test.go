@@ -0,0 +1,87 @@
+package dokan
+
+import (
+	"encoding/binary"
+	"testing"
+	"unicode/utf16"
+	"unsafe"
+)
+
+func putUTF16(buf []byte, s string) {
+	for i, c := range utf16.Encode([]rune(s)) {
+		binary.LittleEndian.PutUint16(buf[i*2:], c)
+	}
+}
+
+func TestDecodeMountPoints(t *testing.T) {
+	const size = 788 // sizeof(DOKAN_MOUNT_POINT_INFO)
+	if unsafe.Sizeof(nativeMountPointInfo{}) != size {
+		t.Fatal("unexpected size of nativeMountPointInfo: ", unsafe.Sizeof(nativeMountPointInfo{}))
+	}
+
+	buf := make([]byte, size*2)
+	binary.LittleEndian.PutUint32(buf[0:], 1)
+	putUTF16(buf[4:], `\DosDevices\X:`)
+	putUTF16(buf[524:], ``)
+	putUTF16(buf[652:], `\Device\Volume{1}`)
+	binary.LittleEndian.PutUint32(buf[780:], 3)
+	binary.LittleEndian.PutUint32(buf[784:], DOKAN_OPTION_ALT_STREAM|DOKAN_OPTION_REMOVABLE)
+
+	b := buf[size:]
+	binary.LittleEndian.PutUint32(b[0:], 2)
+	putUTF16(b[4:], `\DosDevices\C:\mnt\dokan`)
+	putUTF16(b[524:], `server\share`)
+	putUTF16(b[652:], `\Device\Volume{2}`)
+	binary.LittleEndian.PutUint32(b[780:], 5)
+	binary.LittleEndian.PutUint32(b[784:], DOKAN_OPTION_NETWORK|0x10000)
+
+	mps := decodeMountPoints(unsafe.Slice((*nativeMountPointInfo)(unsafe.Pointer(&buf[0])), 2))
+	if len(mps) != 2 {
+		t.Fatal("unexpected mount points: ", mps)
+	}
+	expected := []MountPointInfo{
+		{Type: 1, MountPoint: `\DosDevices\X:`, DeviceName: `\Device\Volume{1}`, SessionID: 3, MountOptions: DOKAN_OPTION_ALT_STREAM | DOKAN_OPTION_REMOVABLE},
+		{Type: 2, MountPoint: `\DosDevices\C:\mnt\dokan`, UNCName: `\\server\share`, DeviceName: `\Device\Volume{2}`, SessionID: 5, MountOptions: DOKAN_OPTION_NETWORK | 0x10000},
+	}
+	for i, mp := range mps {
+		if *mp != expected[i] {
+			t.Errorf("mount point[%d] = %+v, expected %+v", i, *mp, expected[i])
+		}
+	}
+	if mps[0].MountOptions.String() != "ALT_STREAM|REMOVABLE" {
+		t.Error("unexpected flags: ", mps[0].MountOptions)
+	}
+	if mps[1].MountOptions.String() != "NETWORK|0x10000" {
+		t.Error("unexpected flags: ", mps[1].MountOptions)
+	}
+	if !mps[1].MountOptions.Has(DOKAN_OPTION_NETWORK) || mps[0].MountOptions.Has(DOKAN_OPTION_NETWORK) {
+		t.Error("Has() returns unexpected value")
+	}
+
+	if l := len(filterMountPoints(mps, MountPointFilter{UNCOnly: true})); l != 1 {
+		t.Error("filtered mount points should be 1: ", l)
+	}
+
+	mi := &MountInfo{mountPoint: `C:\mnt\dokan\`}
+	instancesLock.Lock()
+	instances[mi] = struct{}{}
+	instancesLock.Unlock()
+	defer func() {
+		instancesLock.Lock()
+		delete(instances, mi)
+		instancesLock.Unlock()
+	}()
+	filtered := filterMountPoints(mps, MountPointFilter{CurrentProcessOnly: true})
+	if len(filtered) != 1 || filtered[0] != mps[1] {
+		t.Error("unexpected filtered mount points: ", filtered)
+	}
+}
+
+func TestMountFlags_String(t *testing.T) {
+	if s := MountFlags(0).String(); s != "0" {
+		t.Error("unexpected String(): ", s)
+	}
+	if s := MountFlags(DOKAN_OPTION_DEBUG | DOKAN_OPTION_FILELOCK_USER_MODE).String(); s != "DEBUG|FILELOCK_USER_MODE" {
+		t.Error("unexpected String(): ", s)
+	}
+}
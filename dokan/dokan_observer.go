@@ -0,0 +1,116 @@
+package dokan
+
+import (
+	"errors"
+	"log"
+	"time"
+)
+
+var (
+	errNotOpened   = errors.New("not opened file")
+	errNoMountInfo = errors.New("no mount info")
+)
+
+// OpEvent represents a completed Dokan operation.
+type OpEvent struct {
+	Op        string // e.g. "CreateFile", "ReadFile"
+	Path      string
+	NewPath   string // MoveFile
+	ProcessID uint32
+
+	// CreateFile
+	Access      uint32
+	Disposition uint32
+
+	// ReadFile, WriteFile, SetEndOfFile, SetAllocationSize
+	Offset int64
+	Length int // requested bytes
+	Bytes  int // transferred bytes
+
+	Status   NTStatus
+	Duration time.Duration
+	Err      error // reported by ReportError
+}
+
+// Observer receives an event for each operation.
+// Observe is called concurrently from Dokan threads.
+type Observer interface {
+	Observe(ev *OpEvent)
+}
+
+// ObserverFunc is an adapter to use a function as Observer.
+type ObserverFunc func(ev *OpEvent)
+
+func (f ObserverFunc) Observe(ev *OpEvent) {
+	f(ev)
+}
+
+type opEvent struct {
+	OpEvent
+	mi    *MountInfo
+	finfo *FileInfo
+	start time.Time
+}
+
+func getMountInfo(finfo *FileInfo) *MountInfo {
+	if finfo == nil || finfo.DokanOptions == nil {
+		return nil
+	}
+	return (*MountInfo)(finfo.DokanOptions.GlobalContext)
+}
+
+// startOp starts tracing an operation. Event is recorded and sent to the Observer by finish().
+func (mi *MountInfo) startOp(op string, path *uint16, finfo *FileInfo) *opEvent {
+	e := &opEvent{OpEvent: OpEvent{Op: op}, mi: mi, finfo: finfo}
+	if mi == nil {
+		return e
+	}
+	e.start = time.Now()
+	if mi.observer == nil {
+		return e
+	}
+	e.ProcessID = finfo.ProcessId
+	if path != nil {
+		e.Path = utf16PtrToString(path)
+	}
+	mi.inflight.Store(finfo, e)
+	return e
+}
+
+func (e *opEvent) finish(status NTStatus) NTStatus {
+	if e.mi == nil {
+		return status
+	}
+	e.Status = status
+	e.Duration = time.Since(e.start)
+	e.mi.metrics.record(&e.OpEvent)
+	if e.mi.observer != nil {
+		e.mi.inflight.Delete(e.finfo)
+		e.mi.observer.Observe(&e.OpEvent)
+	}
+	return status
+}
+
+// fail finishes the operation with err.
+func (e *opEvent) fail(err error, status NTStatus) NTStatus {
+	if e.mi == nil || e.mi.observer == nil {
+		log.Println("ERROR:", e.Op+":", err)
+	}
+	e.Err = err
+	return e.finish(status)
+}
+
+// ReportError reports an error that occurred while processing the request of finfo.
+// If the Observer is set, the error is attached to the event of the request. Otherwise it is logged.
+func ReportError(finfo *FileInfo, op string, err error) {
+	mi := getMountInfo(finfo)
+	if mi == nil || mi.observer == nil {
+		log.Println("ERROR:", op+":", err)
+		return
+	}
+	if e, ok := mi.inflight.Load(finfo); ok {
+		e.(*opEvent).Err = err
+		return
+	}
+	mi.observer.Observe(&OpEvent{Op: op, ProcessID: finfo.ProcessId, Err: err})
+}
@@ -0,0 +1,64 @@
+package dokan
+
+import (
+	"errors"
+	"testing"
+	"unicode/utf16"
+	"unsafe"
+)
+
+func TestObserver(t *testing.T) {
+	var events []OpEvent
+	mi := newMountInfo(&nopDisk{})
+	mi.observer = ObserverFunc(func(ev *OpEvent) { events = append(events, *ev) })
+	finfo := &FileInfo{ProcessId: 123, DokanOptions: &DokanOptions{GlobalContext: unsafe.Pointer(mi)}}
+
+	name := utf16.Encode([]rune(`\a.txt` + "\x00"))
+	ev := getMountInfo(finfo).startOp("ReadFile", &name[0], finfo)
+	ev.Offset = 10
+	ev.Length = 100
+	ev.Bytes = 50
+	errTest := errors.New("test")
+	ReportError(finfo, "ReadFile", errTest)
+	if status := ev.finish(STATUS_SUCCESS); status != STATUS_SUCCESS {
+		t.Error("finish() should return the status", status)
+	}
+
+	ev = getMountInfo(finfo).startOp("Cleanup", &name[0], finfo)
+	if status := ev.fail(errNotOpened, STATUS_INVALID_PARAMETER); status != STATUS_INVALID_PARAMETER {
+		t.Error("fail() should return the status", status)
+	}
+
+	ReportError(finfo, "FindFiles", errTest) // no operation in progress
+
+	if len(events) != 3 {
+		t.Fatal("unexpected events: ", events)
+	}
+	e := events[0]
+	if e.Op != "ReadFile" || e.Path != `\a.txt` || e.ProcessID != 123 || e.Offset != 10 || e.Length != 100 || e.Bytes != 50 || e.Err != errTest {
+		t.Error("unexpected event: ", e)
+	}
+	if events[1].Op != "Cleanup" || events[1].Status != STATUS_INVALID_PARAMETER || events[1].Err != errNotOpened {
+		t.Error("unexpected event: ", events[1])
+	}
+	if events[2].Op != "FindFiles" || events[2].Err != errTest {
+		t.Error("unexpected event: ", events[2])
+	}
+}
+
+func TestObserver_NoObserver(t *testing.T) {
+	mi := newMountInfo(&nopDisk{})
+	finfo := &FileInfo{DokanOptions: &DokanOptions{GlobalContext: unsafe.Pointer(mi)}}
+
+	ev := getMountInfo(finfo).startOp("ReadFile", nil, finfo)
+	if status := ev.finish(STATUS_END_OF_FILE); status != STATUS_END_OF_FILE {
+		t.Error("finish() should return the status", status)
+	}
+	ReportError(finfo, "ReadFile", errNotOpened)
+	ReportError(&FileInfo{}, "ReadFile", errNotOpened)
+
+	ev = (*MountInfo)(nil).startOp("ReadFile", nil, finfo)
+	if status := ev.fail(errNotOpened, STATUS_INVALID_PARAMETER); status != STATUS_INVALID_PARAMETER {
+		t.Error("fail() should return the status", status)
+	}
+}
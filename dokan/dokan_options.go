@@ -0,0 +1,153 @@
+package dokan
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+	"unicode/utf16"
+	"unsafe"
+)
+
+var ErrInvalidOption = errors.New("Invalid option")
+
+const optionFlagsMask = DOKAN_OPTION_DEBUG | DOKAN_OPTION_STDERR | DOKAN_OPTION_ALT_STREAM | DOKAN_OPTION_WRITE_PROTECT |
+	DOKAN_OPTION_NETWORK | DOKAN_OPTION_REMOVABLE | DOKAN_OPTION_MOUNT_MANAGER | DOKAN_OPTION_CURRENT_SESSION | DOKAN_OPTION_FILELOCK_USER_MODE
+
+// Options represents parameters of DokanOptions.
+// Zero values mean the defaults of Dokan.
+type Options struct {
+	// DOKAN_OPTION_* flags
+	Flags uint32
+	// Handle requests in a single thread
+	SingleThread bool
+	// Max time to process a request (default: DefaultTimeout)
+	Timeout time.Duration
+	// Allocation unit size of the volume in bytes
+	AllocationUnitSize uint32
+	// Sector size of the volume in bytes
+	SectorSize uint32
+	// UNC name for network volumes. e.g. \\myserver\share
+	// DOKAN_OPTION_NETWORK is enabled automatically if UNCName is set.
+	UNCName string
+	// Security descriptor of the volume in self-relative format
+	VolumeSecurityDescriptor []byte
+
+	// Observer receives an event for each operation. (optional)
+	Observer Observer
+}
+
+// Validate checks options can be passed to Dokan.
+func (o *Options) Validate() error {
+	if o.Flags&^optionFlagsMask != 0 {
+		return fmt.Errorf("%w: unknown flags %#x", ErrInvalidOption, o.Flags&^optionFlagsMask)
+	}
+	if o.Timeout < 0 || o.Timeout > math.MaxUint32*time.Millisecond {
+		return fmt.Errorf("%w: timeout out of range %v", ErrInvalidOption, o.Timeout)
+	}
+	if o.Timeout > 0 && o.Timeout < time.Millisecond {
+		return fmt.Errorf("%w: timeout too short %v", ErrInvalidOption, o.Timeout)
+	}
+	if o.AllocationUnitSize&(o.AllocationUnitSize-1) != 0 {
+		return fmt.Errorf("%w: allocation unit size must be a power of 2", ErrInvalidOption)
+	}
+	if o.SectorSize&(o.SectorSize-1) != 0 {
+		return fmt.Errorf("%w: sector size must be a power of 2", ErrInvalidOption)
+	}
+	if o.AllocationUnitSize != 0 && o.SectorSize != 0 && o.AllocationUnitSize < o.SectorSize {
+		return fmt.Errorf("%w: allocation unit size must be a multiple of sector size", ErrInvalidOption)
+	}
+	if o.UNCName != "" {
+		unc, err := NormalizeUNCName(o.UNCName)
+		if err != nil {
+			return err
+		}
+		if len(utf16.Encode([]rune(unc))) >= len(nativeMountPointInfo{}.UNCName) {
+			return fmt.Errorf("%w: UNC name too long", ErrInvalidOption)
+		}
+		if o.Flags&DOKAN_OPTION_MOUNT_MANAGER != 0 {
+			return fmt.Errorf("%w: mount manager cannot be used on network drive", ErrInvalidOption)
+		}
+	}
+	if len(o.VolumeSecurityDescriptor) > VOLUME_SECURITY_DESCRIPTOR_MAX_SIZE {
+		return fmt.Errorf("%w: security descriptor too large", ErrInvalidOption)
+	}
+	return nil
+}
+
+// nativeOptions returns DokanOptions for the options.
+// Returned buffers are referenced by DokanOptions and must be kept alive while the volume is mounted.
+func (o *Options) nativeOptions(mountPoint string) (options *DokanOptions, buffers [][]uint16, err error) {
+	if err := o.Validate(); err != nil {
+		return nil, nil, err
+	}
+	if strings.ContainsRune(mountPoint, 0) {
+		return nil, nil, fmt.Errorf("%w: mount point contains NUL", ErrInvalidOption)
+	}
+	flags := o.Flags
+	if o.UNCName != "" {
+		flags |= DOKAN_OPTION_NETWORK
+	} else if mountPoint == "" {
+		return nil, nil, ErrBadMountPoint
+	}
+	options = &DokanOptions{
+		Version:                        DOKAN_MINIMUM_COMPATIBLE_VERSION,
+		Options:                        flags,
+		Timeout:                        uint32(o.Timeout / time.Millisecond),
+		AllocationUnitSize:             o.AllocationUnitSize,
+		SectorSize:                     o.SectorSize,
+		VolumeSecurityDescriptorLength: uint32(len(o.VolumeSecurityDescriptor)),
+	}
+	if o.SingleThread {
+		options.SingleThread = 1
+	}
+	copy(options.VolumeSecurityDescriptor[:], o.VolumeSecurityDescriptor)
+
+	if mountPoint != "" {
+		mp := utf16.Encode([]rune(mountPoint + "\x00"))
+		options.MountPoint = unsafe.Pointer(&mp[0])
+		buffers = append(buffers, mp)
+	}
+	if o.UNCName != "" {
+		name, _ := NormalizeUNCName(o.UNCName)
+		unc := utf16.Encode([]rune(name + "\x00"))
+		options.UNCName = unsafe.Pointer(&unc[0])
+		buffers = append(buffers, unc)
+	}
+	return options, buffers, nil
+}
+
+// NormalizeUNCName validates UNC name like \\server\share and returns the form for Dokan. (\server\share)
+func NormalizeUNCName(name string) (string, error) {
+	if !strings.HasPrefix(name, `\`) {
+		return "", fmt.Errorf("%w: UNC name must start with \\\\: %q", ErrInvalidOption, name)
+	}
+	name = strings.TrimPrefix(strings.TrimPrefix(name, `\`), `\`)
+	parts := strings.Split(strings.TrimSuffix(name, `\`), `\`)
+	if len(parts) < 2 {
+		return "", fmt.Errorf("%w: UNC name must contain server and share: %q", ErrInvalidOption, name)
+	}
+	for _, p := range parts {
+		if p == "" || p == "." || p == ".." || strings.ContainsAny(p, "/:*?\"<>|\x00") {
+			return "", fmt.Errorf("%w: invalid UNC name: %q", ErrInvalidOption, name)
+		}
+	}
+	return `\` + strings.Join(parts, `\`), nil
+}
+
+// uncNameFromNative converts UNC name from the form for Dokan. (\server\share -> \\server\share)
+func uncNameFromNative(name string) string {
+	if name == "" {
+		return name
+	}
+	return `\\` + strings.TrimLeft(name, `\`)
+}
+
+func utf16PtrToString(p *uint16) string {
+	var buf []uint16
+	for ; *p != 0; p = (*uint16)(unsafe.Add(unsafe.Pointer(p), 2)) {
+		buf = append(buf, *p)
+	}
+	return string(utf16.Decode(buf))
+}
@@ -0,0 +1,111 @@
+package dokan
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+	"unsafe"
+)
+
+func TestOptions_Validate(t *testing.T) {
+	valid := []Options{
+		{},
+		{Flags: DOKAN_OPTION_NETWORK | DOKAN_OPTION_ALT_STREAM, Timeout: time.Minute},
+		{AllocationUnitSize: 4096, SectorSize: 512},
+		{UNCName: `\\myserver\share\sub`, VolumeSecurityDescriptor: make([]byte, VOLUME_SECURITY_DESCRIPTOR_MAX_SIZE)},
+	}
+	for _, opt := range valid {
+		if err := opt.Validate(); err != nil {
+			t.Errorf("Validate(%+v) error: %v", opt, err)
+		}
+	}
+
+	invalid := []Options{
+		{Flags: 0x10000},
+		{Timeout: -1},
+		{Timeout: time.Microsecond},
+		{Timeout: 50 * 24 * time.Hour},
+		{AllocationUnitSize: 1000},
+		{SectorSize: 100},
+		{AllocationUnitSize: 512, SectorSize: 4096},
+		{UNCName: `\\s\` + strings.Repeat("a", 64)},
+		{UNCName: "\\\\server\\share\x00"},
+		{UNCName: `server\share`},
+		{UNCName: `\\server`},
+		{UNCName: `\\server\`},
+		{UNCName: `\\ser:ver\share`},
+		{UNCName: `\\server\share`, Flags: DOKAN_OPTION_MOUNT_MANAGER},
+		{VolumeSecurityDescriptor: make([]byte, VOLUME_SECURITY_DESCRIPTOR_MAX_SIZE+1)},
+	}
+	for _, opt := range invalid {
+		if err := opt.Validate(); !errors.Is(err, ErrInvalidOption) {
+			t.Errorf("Validate(%+v) should fail with ErrInvalidOption: %v", opt, err)
+		}
+	}
+}
+
+func TestOptions_NativeOptions(t *testing.T) {
+	opt := &Options{
+		Flags:                    DOKAN_OPTION_ALT_STREAM,
+		SingleThread:             true,
+		Timeout:                  3 * time.Second,
+		AllocationUnitSize:       4096,
+		SectorSize:               512,
+		UNCName:                  `\\server\share`,
+		VolumeSecurityDescriptor: []byte{1, 2, 3},
+	}
+	native, buffers, err := opt.nativeOptions(`X:\`)
+	if err != nil {
+		t.Fatal("nativeOptions() error", err)
+	}
+	if native.Options != DOKAN_OPTION_ALT_STREAM|DOKAN_OPTION_NETWORK || native.SingleThread != 1 || native.Timeout != 3000 ||
+		native.AllocationUnitSize != 4096 || native.SectorSize != 512 || native.VolumeSecurityDescriptorLength != 3 {
+		t.Errorf("unexpected options: %+v", native)
+	}
+	if len(buffers) != 2 || unsafe.Pointer(&buffers[0][0]) != native.MountPoint || unsafe.Pointer(&buffers[1][0]) != native.UNCName {
+		t.Error("buffers should be referenced by options")
+	}
+	if s := string(utf16Runes(buffers[1])); s != `\server\share` {
+		t.Error("unexpected UNC name: ", s)
+	}
+
+	if _, _, err := opt.nativeOptions("X:\x00"); !errors.Is(err, ErrInvalidOption) {
+		t.Error("nativeOptions() should fail with ErrInvalidOption", err)
+	}
+
+	// UNC only
+	native, buffers, err = opt.nativeOptions("")
+	if err != nil {
+		t.Fatal("nativeOptions() error", err)
+	}
+	if native.MountPoint != nil || len(buffers) != 1 {
+		t.Error("MountPoint should be nil")
+	}
+	mi := &MountInfo{options: native}
+	if mi.UNCName() != `\\server\share` {
+		t.Error("unexpected UNCName(): ", mi.UNCName())
+	}
+	if _, _, err := (&Options{}).nativeOptions(""); err != ErrBadMountPoint {
+		t.Error("nativeOptions() without mount point should fail with ErrBadMountPoint", err)
+	}
+}
+
+func TestNormalizeUNCName(t *testing.T) {
+	for _, name := range []string{`\\server\share`, `\server\share`, `\\server\share\`} {
+		if unc, err := NormalizeUNCName(name); err != nil || unc != `\server\share` {
+			t.Errorf("NormalizeUNCName(%q) = %q, %v", name, unc, err)
+		}
+	}
+}
+
+func utf16Runes(buf []uint16) []rune {
+	var r []rune
+	for _, c := range buf {
+		if c == 0 {
+			break
+		}
+		r = append(r, rune(c))
+	}
+	return r
+}
@@ -0,0 +1,148 @@
+package dokan
+
+import (
+	"encoding/binary"
+	"errors"
+	"strings"
+	"unicode/utf16"
+)
+
+// Reparse tags
+const (
+	IO_REPARSE_TAG_MOUNT_POINT = 0xA0000003 // junction
+	IO_REPARSE_TAG_SYMLINK     = 0xA000000C
+
+	// SymbolicLinkReparseBuffer.Flags
+	SYMLINK_FLAG_RELATIVE = 1
+)
+
+var ErrInvalidReparseBuffer = errors.New("Invalid reparse buffer")
+
+// ReparsePointHandle is an optional interface for FileHandle to get and set the reparse point.
+// NOTE: Dokan doesn't forward FSCTL_GET_REPARSE_POINT and FSCTL_SET_REPARSE_POINT to user mode for now.
+// Middlewares and tools can use this interface to access the links. FileHandleFuncs and Recorder forward it.
+type ReparsePointHandle interface {
+	GetReparsePoint(finfo *FileInfo) ([]byte, NTStatus)
+	SetReparsePoint(buf []byte, finfo *FileInfo) NTStatus
+}
+
+// getReparsePoint calls f.GetReparsePoint if implemented.
+func getReparsePoint(f FileHandle, finfo *FileInfo) ([]byte, NTStatus) {
+	if f, ok := f.(ReparsePointHandle); ok {
+		return f.GetReparsePoint(finfo)
+	}
+	return nil, STATUS_NOT_A_REPARSE_POINT
+}
+
+// setReparsePoint calls f.SetReparsePoint if implemented.
+func setReparsePoint(f FileHandle, buf []byte, finfo *FileInfo) NTStatus {
+	if f, ok := f.(ReparsePointHandle); ok {
+		return f.SetReparsePoint(buf, finfo)
+	}
+	return STATUS_NOT_SUPPORTED
+}
+
+// ReparsePoint represents a symbolic link or a junction.
+type ReparsePoint struct {
+	Tag      uint32 // IO_REPARSE_TAG_SYMLINK or IO_REPARSE_TAG_MOUNT_POINT
+	Target   string // substitute name. e.g. \??\C:\dir or ..\dir
+	Print    string // print name. e.g. C:\dir
+	Relative bool   // SYMLINK_FLAG_RELATIVE
+}
+
+// NewSymlinkReparsePoint returns a ReparsePoint for the symbolic link to target.
+// Slashes in target are converted to backslashes.
+func NewSymlinkReparsePoint(target string) *ReparsePoint {
+	target = strings.ReplaceAll(target, "/", `\`)
+	rp := &ReparsePoint{Tag: IO_REPARSE_TAG_SYMLINK, Target: target, Print: target}
+	if len(target) >= 2 && target[1] == ':' {
+		rp.Target = `\??\` + target
+	} else if !strings.HasPrefix(target, `\`) {
+		rp.Relative = true
+	}
+	return rp
+}
+
+// Encode returns REPARSE_DATA_BUFFER of the reparse point.
+func (rp *ReparsePoint) Encode() []byte {
+	target := utf16.Encode([]rune(rp.Target))
+	printName := utf16.Encode([]rune(rp.Print))
+	header := 8 // SubstituteNameOffset, SubstituteNameLength, PrintNameOffset, PrintNameLength
+	if rp.Tag == IO_REPARSE_TAG_SYMLINK {
+		header += 4 // Flags
+	}
+	// Names are terminated by NUL, which is not counted in the lengths.
+	pathLen := (len(target) + 1 + len(printName) + 1) * 2
+	buf := make([]byte, 8+header+pathLen)
+	binary.LittleEndian.PutUint32(buf[0:], rp.Tag)
+	binary.LittleEndian.PutUint16(buf[4:], uint16(header+pathLen))
+	binary.LittleEndian.PutUint16(buf[8:], 0)
+	binary.LittleEndian.PutUint16(buf[10:], uint16(len(target)*2))
+	binary.LittleEndian.PutUint16(buf[12:], uint16((len(target)+1)*2))
+	binary.LittleEndian.PutUint16(buf[14:], uint16(len(printName)*2))
+	if rp.Tag == IO_REPARSE_TAG_SYMLINK && rp.Relative {
+		binary.LittleEndian.PutUint32(buf[16:], SYMLINK_FLAG_RELATIVE)
+	}
+	p := buf[8+header:]
+	for i, c := range target {
+		binary.LittleEndian.PutUint16(p[i*2:], c)
+	}
+	p = p[(len(target)+1)*2:]
+	for i, c := range printName {
+		binary.LittleEndian.PutUint16(p[i*2:], c)
+	}
+	return buf
+}
+
+// DecodeReparsePoint decodes REPARSE_DATA_BUFFER of a symbolic link or a junction.
+func DecodeReparsePoint(buf []byte) (*ReparsePoint, error) {
+	if len(buf) < 8 {
+		return nil, ErrInvalidReparseBuffer
+	}
+	rp := &ReparsePoint{Tag: binary.LittleEndian.Uint32(buf)}
+	dataLen := int(binary.LittleEndian.Uint16(buf[4:]))
+	if len(buf) < 8+dataLen {
+		return nil, ErrInvalidReparseBuffer
+	}
+	data := buf[8 : 8+dataLen]
+	header := 8
+	switch rp.Tag {
+	case IO_REPARSE_TAG_SYMLINK:
+		header += 4
+	case IO_REPARSE_TAG_MOUNT_POINT:
+	default:
+		return nil, ErrInvalidReparseBuffer
+	}
+	if len(data) < header {
+		return nil, ErrInvalidReparseBuffer
+	}
+	if rp.Tag == IO_REPARSE_TAG_SYMLINK {
+		rp.Relative = binary.LittleEndian.Uint32(data[8:])&SYMLINK_FLAG_RELATIVE != 0
+	}
+	pathBuf := data[header:]
+	name := func(offset, length uint16) (string, bool) {
+		if int(offset)+int(length) > len(pathBuf) || length%2 != 0 {
+			return "", false
+		}
+		s := make([]uint16, length/2)
+		for i := range s {
+			s[i] = binary.LittleEndian.Uint16(pathBuf[int(offset)+i*2:])
+		}
+		return string(utf16.Decode(s)), true
+	}
+	var ok1, ok2 bool
+	rp.Target, ok1 = name(binary.LittleEndian.Uint16(data[0:]), binary.LittleEndian.Uint16(data[2:]))
+	rp.Print, ok2 = name(binary.LittleEndian.Uint16(data[4:]), binary.LittleEndian.Uint16(data[6:]))
+	if !ok1 || !ok2 {
+		return nil, ErrInvalidReparseBuffer
+	}
+	return rp, nil
+}
+
+// LinkTarget returns the target path of the link. The prefix \??\ is removed.
+func (rp *ReparsePoint) LinkTarget() string {
+	if rp.Print != "" {
+		return rp.Print
+	}
+	return strings.TrimPrefix(rp.Target, `\??\`)
+}
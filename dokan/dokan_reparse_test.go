@@ -0,0 +1,67 @@
+package dokan
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+func TestReparsePoint_Symlink(t *testing.T) {
+	rp := NewSymlinkReparsePoint("../dir/a.txt")
+	if rp.Target != `..\dir\a.txt` || !rp.Relative {
+		t.Error("unexpected reparse point: ", rp)
+	}
+	buf := rp.Encode()
+
+	// ReparseTag, ReparseDataLength, Reserved, offsets and lengths, Flags
+	header := []byte{0x0C, 0x00, 0x00, 0xA0, 0x40, 0x00, 0x00, 0x00, 0x00, 0x00, 0x18, 0x00, 0x1A, 0x00, 0x18, 0x00, 0x01, 0x00, 0x00, 0x00}
+	if !bytes.Equal(buf[:len(header)], header) {
+		t.Errorf("unexpected header: % x", buf[:len(header)])
+	}
+	if len(buf) != 8+int(binary.LittleEndian.Uint16(buf[4:])) {
+		t.Error("unexpected length: ", len(buf))
+	}
+
+	decoded, err := DecodeReparsePoint(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *decoded != *rp {
+		t.Errorf("DecodeReparsePoint() = %v, expected %v", decoded, rp)
+	}
+	if decoded.LinkTarget() != `..\dir\a.txt` {
+		t.Error("unexpected target: ", decoded.LinkTarget())
+	}
+
+	abs := NewSymlinkReparsePoint(`C:\dir`)
+	if abs.Target != `\??\C:\dir` || abs.Print != `C:\dir` || abs.Relative {
+		t.Error("unexpected reparse point: ", abs)
+	}
+}
+
+func TestReparsePoint_Junction(t *testing.T) {
+	rp := &ReparsePoint{Tag: IO_REPARSE_TAG_MOUNT_POINT, Target: `\??\C:\dir`, Print: `C:\dir`}
+	buf := rp.Encode()
+	if binary.LittleEndian.Uint16(buf[4:]) != uint16(8+(10+1+6+1)*2) {
+		t.Error("unexpected data length: ", binary.LittleEndian.Uint16(buf[4:]))
+	}
+	decoded, err := DecodeReparsePoint(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *decoded != *rp || decoded.LinkTarget() != `C:\dir` {
+		t.Errorf("DecodeReparsePoint() = %v, expected %v", decoded, rp)
+	}
+
+	// Without print name
+	decoded.Print = ""
+	if decoded.LinkTarget() != `C:\dir` {
+		t.Error("unexpected target: ", decoded.LinkTarget())
+	}
+
+	for _, b := range [][]byte{nil, buf[:len(buf)-1], buf[:12], {1, 0, 0, 0x80, 0, 0, 0, 0}} {
+		if _, err := DecodeReparsePoint(b); err != ErrInvalidReparseBuffer {
+			t.Errorf("DecodeReparsePoint(% x) should fail: %v", b, err)
+		}
+	}
+}
@@ -0,0 +1,8 @@
+package dokan
+
+// Requestor represents the process and the user which issued the request.
+type Requestor struct {
+	ProcessID uint32
+	ImageName string // full path of the executable. e.g. C:\Windows\explorer.exe
+	UserSID   string // e.g. S-1-5-21-...
+}
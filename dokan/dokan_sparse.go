@@ -0,0 +1,49 @@
+package dokan
+
+// AllocationSizeHandle is an optional interface for FileHandle to handle SetAllocationSize.
+// Allocation size is the size reserved on the disk and doesn't change the file size
+// unless it is smaller than the file size.
+type AllocationSizeHandle interface {
+	SetAllocationSize(size int64, finfo *FileInfo) NTStatus
+}
+
+// SparseHandle is an optional interface for FileHandle to support sparse files.
+// NOTE: Dokan doesn't forward FSCTL_SET_SPARSE and FSCTL_SET_ZERO_DATA to user mode for now.
+// Middlewares and tools can use this interface. FileHandleFuncs and Recorder forward it.
+type SparseHandle interface {
+	SetSparse(sparse bool, finfo *FileInfo) NTStatus
+	// ZeroRange deallocates or fills zeros in the range. The file size is not changed.
+	ZeroRange(offset, length int64, finfo *FileInfo) NTStatus
+}
+
+// setAllocationSize calls f.SetAllocationSize if implemented.
+// Otherwise, the file is truncated if the allocation size is smaller than the file size, like mirror sample of Dokan.
+func setAllocationSize(f FileHandle, size int64, finfo *FileInfo) NTStatus {
+	if f, ok := f.(AllocationSizeHandle); ok {
+		return f.SetAllocationSize(size, finfo)
+	}
+	var fi ByHandleFileInfo
+	if status := f.GetFileInformation(&fi, finfo); status != STATUS_SUCCESS {
+		return status
+	}
+	if fileSize := int64(fi.FileSizeHigh)<<32 | int64(fi.FileSizeLow); size < fileSize {
+		return f.SetEndOfFile(size, finfo)
+	}
+	return STATUS_SUCCESS
+}
+
+// setSparse calls f.SetSparse if implemented.
+func setSparse(f FileHandle, sparse bool, finfo *FileInfo) NTStatus {
+	if f, ok := f.(SparseHandle); ok {
+		return f.SetSparse(sparse, finfo)
+	}
+	return STATUS_NOT_SUPPORTED
+}
+
+// zeroRange calls f.ZeroRange if implemented.
+func zeroRange(f FileHandle, offset, length int64, finfo *FileInfo) NTStatus {
+	if f, ok := f.(SparseHandle); ok {
+		return f.ZeroRange(offset, length, finfo)
+	}
+	return STATUS_NOT_SUPPORTED
+}
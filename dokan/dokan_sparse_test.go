@@ -0,0 +1,52 @@
+package dokan
+
+import "testing"
+
+type testSizeHandle struct {
+	FileHandle
+	size      int64
+	allocated int64
+}
+
+func (f *testSizeHandle) GetFileInformation(fi *ByHandleFileInfo, finfo *FileInfo) NTStatus {
+	fi.FileSizeHigh = uint32(f.size >> 32)
+	fi.FileSizeLow = uint32(f.size)
+	return STATUS_SUCCESS
+}
+
+func (f *testSizeHandle) SetEndOfFile(offset int64, finfo *FileInfo) NTStatus {
+	f.size = offset
+	return STATUS_SUCCESS
+}
+
+type testAllocHandle struct {
+	*testSizeHandle
+}
+
+func (f testAllocHandle) SetAllocationSize(size int64, finfo *FileInfo) NTStatus {
+	f.allocated = size
+	return STATUS_SUCCESS
+}
+
+func TestSetAllocationSize(t *testing.T) {
+	f := &testSizeHandle{size: 0x100000000 + 100}
+	if status := setAllocationSize(f, 0x200000000, &FileInfo{}); status != STATUS_SUCCESS || f.size != 0x100000000+100 {
+		t.Error("file size should not be changed by larger allocation size", status, f.size)
+	}
+	if status := setAllocationSize(f, 50, &FileInfo{}); status != STATUS_SUCCESS || f.size != 50 {
+		t.Error("file should be truncated to smaller allocation size", status, f.size)
+	}
+
+	a := testAllocHandle{&testSizeHandle{size: 100}}
+	if status := setAllocationSize(a, 10, &FileInfo{}); status != STATUS_SUCCESS || a.allocated != 10 || a.size != 100 {
+		t.Error("SetAllocationSize() should be called", status, a.allocated, a.size)
+	}
+
+	// Through middleware
+	if status := setAllocationSize(&FileHandleFuncs{Next: a}, 20, &FileInfo{}); status != STATUS_SUCCESS || a.allocated != 20 {
+		t.Error("SetAllocationSize() should be forwarded", status, a.allocated)
+	}
+	if status := setAllocationSize(&FileHandleFuncs{Next: f}, 10, &FileInfo{}); status != STATUS_SUCCESS || f.size != 10 {
+		t.Error("SetAllocationSize() should be forwarded", status, f.size)
+	}
+}
@@ -0,0 +1,16 @@
+package dokan
+
+// StreamsHandle is an optional interface for FileHandle to enumerate alternate data streams.
+// FindStreams is called by Dokan if DOKAN_OPTION_ALT_STREAM is set.
+// Stream names are in the form of ":streamname:$DATA". The default data stream is "::$DATA".
+type StreamsHandle interface {
+	FindStreams(fillFindStreamDataCallBack func(fs *WIN32_FIND_STREAM_DATA) (bufferFull bool, err error), finfo *FileInfo) NTStatus
+}
+
+// findStreams calls f.FindStreams if implemented.
+func findStreams(f FileHandle, fillFindStreamDataCallBack func(fs *WIN32_FIND_STREAM_DATA) (bool, error), finfo *FileInfo) NTStatus {
+	if f, ok := f.(StreamsHandle); ok {
+		return f.FindStreams(fillFindStreamDataCallBack, finfo)
+	}
+	return STATUS_NOT_IMPLEMENTED
+}
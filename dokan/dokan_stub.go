@@ -19,17 +19,29 @@ func Shutdown() error {
 func MountPoints() ([]*MountPointInfo, error) {
 	return nil, ErrFailedToLoadDokan
 }
+func UsedDriveLetters() (string, error) {
+	return "", ErrFailedToLoadDokan
+}
+func ListMountPoints(filter MountPointFilter) ([]*MountPointInfo, error) {
+	return nil, ErrFailedToLoadDokan
+}
 func CreateFileSystem(options *DokanOptions, operations *DokanOperations) (MountHandle, error) {
 	return 0, ErrFailedToLoadDokan
 }
+func waitForFileSystemClosed(handle MountHandle, timeout uint32) (bool, error) {
+	return false, ErrFailedToLoadDokan
+}
+func removeMountPoint(mountPoint string) error {
+	return ErrFailedToLoadDokan
+}
 func closeHandle(handle MountHandle) error {
 	return ErrFailedToLoadDokan
 }
-func UTF16FromString(s string) ([]uint16, error) {
-	return nil, ErrFailedToLoadDokan
+func ResetTimeout(timeout uint32, finfo *FileInfo) bool {
+	return false
 }
-func UTF16PtrFromString(s string) (*uint16, error) {
-	return nil, ErrFailedToLoadDokan
+func GetRequestor(finfo *FileInfo) (*Requestor, error) {
+	return &Requestor{ProcessID: finfo.ProcessId}, ErrFailedToLoadDokan
 }
 
 // notify
@@ -43,3 +43,20 @@ func TestMountPoints(t *testing.T) {
 	}
 	t.Log("MountPoints: ", mp)
 }
+
+func TestMountPointInfo_DriveLetter(t *testing.T) {
+	tests := map[string]string{
+		`\DosDevices\X:`: "X",
+		`\??\y:\`:        "Y",
+		`X:`:             "X",
+		`C:\mount\dir`:   "",
+		``:               "",
+		`1:`:             "",
+	}
+	for mountPoint, expected := range tests {
+		mp := &MountPointInfo{MountPoint: mountPoint}
+		if l := mp.DriveLetter(); l != expected {
+			t.Errorf("DriveLetter(%q) = %q, expected %q", mountPoint, l, expected)
+		}
+	}
+}
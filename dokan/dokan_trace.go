@@ -0,0 +1,353 @@
+package dokan
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"sync"
+)
+
+// TraceEntry is a recorded Disk/FileHandle call. A trace is a JSON-lines stream of TraceEntry.
+type TraceEntry struct {
+	Seq    uint64 `json:"seq"`
+	Handle uint64 `json:"handle,omitempty"` // ID of the handle assigned by CreateFile
+	Op     string `json:"op"`
+
+	Name              string `json:"name,omitempty"` // CreateFile, MoveFile(new name), CreateHardLink(new name)
+	Access            uint32 `json:"access,omitempty"`
+	Attrs             uint32 `json:"attrs,omitempty"`
+	Share             uint32 `json:"share,omitempty"`
+	Disposition       uint32 `json:"disposition,omitempty"`
+	Options           uint32 `json:"options,omitempty"`
+	ReplaceIfExisting bool   `json:"replace,omitempty"`
+	Offset            int64  `json:"offset,omitempty"`
+	Length            int    `json:"length,omitempty"`
+	Sparse            bool   `json:"sparse,omitempty"` // SetSparse
+	Data              []byte `json:"data,omitempty"`   // WriteFile (if Recorder.RecordData), SetReparsePoint
+
+	ProcessID     uint32 `json:"pid,omitempty"`
+	IsDirectory   bool   `json:"dir,omitempty"`
+	DeleteOnClose bool   `json:"delete_on_close,omitempty"`
+
+	Status  NTStatus `json:"status"`
+	Bytes   int      `json:"bytes,omitempty"`   // ReadFile, WriteFile, GetReparsePoint
+	Entries []string `json:"entries,omitempty"` // FindFiles, FindStreams
+}
+
+// Recorder is a Disk that records all calls to the underlying Disk and its FileHandles.
+// Entries are written in the order of completion.
+type Recorder struct {
+	Disk
+	// Record data of WriteFile
+	RecordData bool
+
+	lock       sync.Mutex
+	w          *json.Encoder
+	seq        uint64
+	lastHandle uint64
+	err        error
+}
+
+// NewRecorder returns a Disk which writes a trace of calls to w.
+func NewRecorder(d Disk, w io.Writer) *Recorder {
+	return &Recorder{Disk: d, w: json.NewEncoder(w)}
+}
+
+// Err returns the first error occurred while writing the trace.
+func (r *Recorder) Err() error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.err
+}
+
+func (r *Recorder) record(e *TraceEntry, finfo *FileInfo) {
+	if finfo != nil {
+		e.ProcessID = finfo.ProcessId
+		e.IsDirectory = finfo.IsDirectory != 0
+		e.DeleteOnClose = finfo.IsDeleteOnClose()
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.seq++
+	e.Seq = r.seq
+	if err := r.w.Encode(e); err != nil && r.err == nil {
+		r.err = err
+	}
+}
+
+func (r *Recorder) GetVolumeInformation(finfo *FileInfo) (VolumeInformation, NTStatus) {
+	vi, status := r.Disk.GetVolumeInformation(finfo)
+	r.record(&TraceEntry{Op: "GetVolumeInformation", Status: status}, finfo)
+	return vi, status
+}
+
+func (r *Recorder) GetDiskFreeSpace(availableBytes *uint64, totalBytes *uint64, freeBytes *uint64, finfo *FileInfo) NTStatus {
+	status := r.Disk.GetDiskFreeSpace(availableBytes, totalBytes, freeBytes, finfo)
+	r.record(&TraceEntry{Op: "GetDiskFreeSpace", Status: status}, finfo)
+	return status
+}
+
+func (r *Recorder) CreateFile(name string, secCtx uintptr, access, attrs, share, disposition, options uint32, finfo *FileInfo) (FileHandle, NTStatus) {
+	f, status := r.Disk.CreateFile(name, secCtx, access, attrs, share, disposition, options, finfo)
+	e := &TraceEntry{Op: "CreateFile", Name: name, Access: access, Attrs: attrs, Share: share, Disposition: disposition, Options: options, Status: status}
+	if f != nil {
+		r.lock.Lock()
+		r.lastHandle++
+		e.Handle = r.lastHandle
+		r.lock.Unlock()
+		f = &recordedHandle{FileHandle: f, r: r, id: e.Handle}
+	}
+	r.record(e, finfo)
+	return f, status
+}
+
+type recordedHandle struct {
+	FileHandle
+	r  *Recorder
+	id uint64
+}
+
+func (f *recordedHandle) record(e *TraceEntry, finfo *FileInfo) {
+	e.Handle = f.id
+	f.r.record(e, finfo)
+}
+
+func (f *recordedHandle) FindFiles(fillFindDataCallBack func(fi *WIN32_FIND_DATAW) (bool, error), finfo *FileInfo) NTStatus {
+	var entries []string
+	status := f.FileHandle.FindFiles(func(fi *WIN32_FIND_DATAW) (bool, error) {
+		entries = append(entries, utf16ArrayToString(fi.FileName[:]))
+		return fillFindDataCallBack(fi)
+	}, finfo)
+	f.record(&TraceEntry{Op: "FindFiles", Status: status, Entries: entries}, finfo)
+	return status
+}
+
+func (f *recordedHandle) GetFileInformation(fi *ByHandleFileInfo, finfo *FileInfo) NTStatus {
+	status := f.FileHandle.GetFileInformation(fi, finfo)
+	f.record(&TraceEntry{Op: "GetFileInformation", Status: status}, finfo)
+	return status
+}
+
+func (f *recordedHandle) ReadFile(buf []byte, read *int32, offset int64, finfo *FileInfo) NTStatus {
+	status := f.FileHandle.ReadFile(buf, read, offset, finfo)
+	f.record(&TraceEntry{Op: "ReadFile", Offset: offset, Length: len(buf), Status: status, Bytes: int(*read)}, finfo)
+	return status
+}
+
+func (f *recordedHandle) WriteFile(buf []byte, written *int32, offset int64, finfo *FileInfo) NTStatus {
+	status := f.FileHandle.WriteFile(buf, written, offset, finfo)
+	e := &TraceEntry{Op: "WriteFile", Offset: offset, Length: len(buf), Status: status, Bytes: int(*written)}
+	if f.r.RecordData {
+		e.Data = buf
+	}
+	f.record(e, finfo)
+	return status
+}
+
+func (f *recordedHandle) SetEndOfFile(offset int64, finfo *FileInfo) NTStatus {
+	status := f.FileHandle.SetEndOfFile(offset, finfo)
+	f.record(&TraceEntry{Op: "SetEndOfFile", Offset: offset, Status: status}, finfo)
+	return status
+}
+
+func (f *recordedHandle) SetAllocationSize(size int64, finfo *FileInfo) NTStatus {
+	status := setAllocationSize(f.FileHandle, size, finfo)
+	f.record(&TraceEntry{Op: "SetAllocationSize", Offset: size, Status: status}, finfo)
+	return status
+}
+
+func (f *recordedHandle) GetReparsePoint(finfo *FileInfo) ([]byte, NTStatus) {
+	buf, status := getReparsePoint(f.FileHandle, finfo)
+	f.record(&TraceEntry{Op: "GetReparsePoint", Status: status, Bytes: len(buf)}, finfo)
+	return buf, status
+}
+
+func (f *recordedHandle) SetReparsePoint(buf []byte, finfo *FileInfo) NTStatus {
+	status := setReparsePoint(f.FileHandle, buf, finfo)
+	f.record(&TraceEntry{Op: "SetReparsePoint", Data: buf, Status: status}, finfo)
+	return status
+}
+
+func (f *recordedHandle) CreateHardLink(newname string, replaceIfExisting bool, finfo *FileInfo) NTStatus {
+	status := createHardLink(f.FileHandle, newname, replaceIfExisting, finfo)
+	f.record(&TraceEntry{Op: "CreateHardLink", Name: newname, ReplaceIfExisting: replaceIfExisting, Status: status}, finfo)
+	return status
+}
+
+func (f *recordedHandle) SetSparse(sparse bool, finfo *FileInfo) NTStatus {
+	status := setSparse(f.FileHandle, sparse, finfo)
+	f.record(&TraceEntry{Op: "SetSparse", Sparse: sparse, Status: status}, finfo)
+	return status
+}
+
+func (f *recordedHandle) ZeroRange(offset, length int64, finfo *FileInfo) NTStatus {
+	status := zeroRange(f.FileHandle, offset, length, finfo)
+	f.record(&TraceEntry{Op: "ZeroRange", Offset: offset, Length: int(length), Status: status}, finfo)
+	return status
+}
+
+func (f *recordedHandle) FindStreams(fillFindStreamDataCallBack func(fs *WIN32_FIND_STREAM_DATA) (bool, error), finfo *FileInfo) NTStatus {
+	var entries []string
+	status := findStreams(f.FileHandle, func(fs *WIN32_FIND_STREAM_DATA) (bool, error) {
+		entries = append(entries, utf16ArrayToString(fs.StreamName[:]))
+		return fillFindStreamDataCallBack(fs)
+	}, finfo)
+	f.record(&TraceEntry{Op: "FindStreams", Status: status, Entries: entries}, finfo)
+	return status
+}
+
+func (f *recordedHandle) MoveFile(newname string, replaceIfExisting bool, finfo *FileInfo) NTStatus {
+	status := f.FileHandle.MoveFile(newname, replaceIfExisting, finfo)
+	f.record(&TraceEntry{Op: "MoveFile", Name: newname, ReplaceIfExisting: replaceIfExisting, Status: status}, finfo)
+	return status
+}
+
+func (f *recordedHandle) DeleteFile(finfo *FileInfo) NTStatus {
+	status := f.FileHandle.DeleteFile(finfo)
+	f.record(&TraceEntry{Op: "DeleteFile", Status: status}, finfo)
+	return status
+}
+
+func (f *recordedHandle) DeleteDirectory(finfo *FileInfo) NTStatus {
+	status := f.FileHandle.DeleteDirectory(finfo)
+	f.record(&TraceEntry{Op: "DeleteDirectory", Status: status}, finfo)
+	return status
+}
+
+func (f *recordedHandle) Cleanup(finfo *FileInfo) NTStatus {
+	status := f.FileHandle.Cleanup(finfo)
+	f.record(&TraceEntry{Op: "Cleanup", Status: status}, finfo)
+	return status
+}
+
+func (f *recordedHandle) CloseFile(finfo *FileInfo) {
+	f.FileHandle.CloseFile(finfo)
+	f.record(&TraceEntry{Op: "CloseFile"}, finfo)
+}
+
+func (f *recordedHandle) Flush() error {
+	var err error
+	if fl, ok := f.FileHandle.(Flusher); ok {
+		err = fl.Flush()
+	}
+	f.record(&TraceEntry{Op: "FlushFileBuffers", Status: ErrorToNTStatus(err)}, nil)
+	return err
+}
+
+// TraceDiff is a difference between the recorded and the replayed results.
+type TraceDiff struct {
+	Entry  *TraceEntry
+	Actual NTStatus
+}
+
+// Replay calls methods of d in the order of the trace read from r and returns the calls resulted in different NTStatus.
+// Calls to the handles which were not opened in the replay are skipped.
+func Replay(d Disk, r io.Reader) ([]TraceDiff, error) {
+	type replayedHandle struct {
+		f     FileHandle
+		finfo *FileInfo
+	}
+	handles := map[uint64]*replayedHandle{}
+	var diffs []TraceDiff
+
+	dec := json.NewDecoder(bufio.NewReader(r))
+	for {
+		e := &TraceEntry{}
+		if err := dec.Decode(e); err == io.EOF {
+			break
+		} else if err != nil {
+			return diffs, err
+		}
+
+		var finfo *FileInfo
+		var h *replayedHandle
+		if e.Op == "CreateFile" || e.Handle == 0 {
+			finfo = &FileInfo{}
+		} else if h = handles[e.Handle]; h != nil {
+			finfo = h.finfo
+		} else {
+			continue
+		}
+		finfo.ProcessId = e.ProcessID
+		finfo.IsDirectory = boolToUint8(e.IsDirectory)
+		finfo.DeleteOnClose = boolToUint8(e.DeleteOnClose)
+
+		var status NTStatus
+		var n int32
+		switch e.Op {
+		case "GetVolumeInformation":
+			_, status = d.GetVolumeInformation(finfo)
+		case "GetDiskFreeSpace":
+			var a, t, f uint64
+			status = d.GetDiskFreeSpace(&a, &t, &f, finfo)
+		case "CreateFile":
+			var f FileHandle
+			f, status = d.CreateFile(e.Name, 0, e.Access, e.Attrs, e.Share, e.Disposition, e.Options, finfo)
+			if f != nil {
+				if e.Handle == 0 {
+					// Unexpectedly opened.
+					f.Cleanup(finfo)
+					f.CloseFile(finfo)
+				} else {
+					handles[e.Handle] = &replayedHandle{f: f, finfo: finfo}
+				}
+			}
+		case "FindFiles":
+			status = h.f.FindFiles(func(fi *WIN32_FIND_DATAW) (bool, error) { return false, nil }, finfo)
+		case "GetFileInformation":
+			status = h.f.GetFileInformation(&ByHandleFileInfo{}, finfo)
+		case "ReadFile":
+			status = h.f.ReadFile(make([]byte, e.Length), &n, e.Offset, finfo)
+		case "WriteFile":
+			data := e.Data
+			if len(data) != e.Length {
+				data = make([]byte, e.Length)
+			}
+			status = h.f.WriteFile(data, &n, e.Offset, finfo)
+		case "SetEndOfFile":
+			status = h.f.SetEndOfFile(e.Offset, finfo)
+		case "SetAllocationSize":
+			status = setAllocationSize(h.f, e.Offset, finfo)
+		case "GetReparsePoint":
+			_, status = getReparsePoint(h.f, finfo)
+		case "SetReparsePoint":
+			status = setReparsePoint(h.f, e.Data, finfo)
+		case "CreateHardLink":
+			status = createHardLink(h.f, e.Name, e.ReplaceIfExisting, finfo)
+		case "SetSparse":
+			status = setSparse(h.f, e.Sparse, finfo)
+		case "ZeroRange":
+			status = zeroRange(h.f, e.Offset, int64(e.Length), finfo)
+		case "FindStreams":
+			status = findStreams(h.f, func(fs *WIN32_FIND_STREAM_DATA) (bool, error) { return false, nil }, finfo)
+		case "MoveFile":
+			status = h.f.MoveFile(e.Name, e.ReplaceIfExisting, finfo)
+		case "DeleteFile":
+			status = h.f.DeleteFile(finfo)
+		case "DeleteDirectory":
+			status = h.f.DeleteDirectory(finfo)
+		case "Cleanup":
+			status = h.f.Cleanup(finfo)
+		case "CloseFile":
+			h.f.CloseFile(finfo)
+			delete(handles, e.Handle)
+		case "FlushFileBuffers":
+			if f, ok := h.f.(Flusher); ok {
+				status = ErrorToNTStatus(f.Flush())
+			}
+		default:
+			continue
+		}
+		if status != e.Status {
+			diffs = append(diffs, TraceDiff{Entry: e, Actual: status})
+		}
+	}
+	return diffs, nil
+}
+
+func boolToUint8(b bool) uint8 {
+	if b {
+		return 1
+	}
+	return 0
+}
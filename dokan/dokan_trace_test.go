@@ -0,0 +1,21 @@
+package dokan
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestReplay_Malformed(t *testing.T) {
+	trace := `{"seq":1,"handle":3,"op":"ReadFile","length":10,"status":0}
+{"seq":2,"op":"CreateFile","name":"\\a.txt","status":3221225524}
+{"seq":3,"op":"GetDiskFreeSpace","status":0}
+broken`
+	diffs, err := Replay(&nopDisk{}, strings.NewReader(trace))
+	if err == nil {
+		t.Error("Replay() should fail")
+	}
+	// ReadFile is skipped. CreateFile returns STATUS_NOT_SUPPORTED instead of STATUS_OBJECT_NAME_NOT_FOUND.
+	if len(diffs) != 2 || diffs[0].Entry.Seq != 2 || diffs[0].Actual != STATUS_NOT_SUPPORTED || diffs[1].Entry.Seq != 3 {
+		t.Error("unexpected diffs: ", diffs)
+	}
+}
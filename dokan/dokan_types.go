@@ -27,18 +27,27 @@ type NTStatus uintptr
 
 // NTSTATUS
 const (
-	STATUS_SUCCESS               = NTStatus(0)
-	STATUS_INVALID_PARAMETER     = NTStatus(0xC000000D)
-	STATUS_END_OF_FILE           = NTStatus(0xC0000011)
-	STATUS_ACCESS_DENIED         = NTStatus(0xC0000022)
-	STATUS_OBJECT_NAME_NOT_FOUND = NTStatus(0xC0000034)
-	STATUS_OBJECT_NAME_COLLISION = NTStatus(0xC0000035)
-	STATUS_OBJECT_PATH_NOT_FOUND = NTStatus(0xC000003A)
-	STATUS_FILE_IS_A_DIRECTORY   = NTStatus(0xC00000BA)
-	STATUS_NOT_SAME_DEVICE       = NTStatus(0xC00000D4)
-	STATUS_NOT_SUPPORTED         = NTStatus(0xC00000BB)
-	STATUS_DIRECTORY_NOT_EMPTY   = NTStatus(0xC0000101)
-	STATUS_NOT_A_DIRECTORY       = NTStatus(0xC0000103)
+	STATUS_SUCCESS                 = NTStatus(0)
+	STATUS_NOT_IMPLEMENTED         = NTStatus(0xC0000002)
+	STATUS_INVALID_PARAMETER       = NTStatus(0xC000000D)
+	STATUS_END_OF_FILE             = NTStatus(0xC0000011)
+	STATUS_ACCESS_DENIED           = NTStatus(0xC0000022)
+	STATUS_OBJECT_NAME_NOT_FOUND   = NTStatus(0xC0000034)
+	STATUS_OBJECT_NAME_COLLISION   = NTStatus(0xC0000035)
+	STATUS_OBJECT_PATH_NOT_FOUND   = NTStatus(0xC000003A)
+	STATUS_SHARING_VIOLATION       = NTStatus(0xC0000043)
+	STATUS_DELETE_PENDING          = NTStatus(0xC0000056)
+	STATUS_DISK_FULL               = NTStatus(0xC000007F)
+	STATUS_IO_TIMEOUT              = NTStatus(0xC00000B5)
+	STATUS_FILE_IS_A_DIRECTORY     = NTStatus(0xC00000BA)
+	STATUS_NOT_SAME_DEVICE         = NTStatus(0xC00000D4)
+	STATUS_DEVICE_NOT_READY        = NTStatus(0xC00000A3)
+	STATUS_NOT_SUPPORTED           = NTStatus(0xC00000BB)
+	STATUS_DIRECTORY_NOT_EMPTY     = NTStatus(0xC0000101)
+	STATUS_NOT_A_DIRECTORY         = NTStatus(0xC0000103)
+	STATUS_CANCELLED               = NTStatus(0xC0000120)
+	STATUS_NOT_A_REPARSE_POINT     = NTStatus(0xC0000275)
+	STATUS_IO_REPARSE_DATA_INVALID = NTStatus(0xC0000278)
 )
 
 // File attribute
@@ -49,6 +58,28 @@ const (
 	FILE_ATTRIBUTE_DIRECTORY = 16
 	FILE_ATTRIBUTE_ARCHIVE   = 32
 	FILE_ATTRIBUTE_NORMAL    = 128
+
+	FILE_ATTRIBUTE_TEMPORARY           = 0x100
+	FILE_ATTRIBUTE_SPARSE_FILE         = 0x200
+	FILE_ATTRIBUTE_REPARSE_POINT       = 0x400
+	FILE_ATTRIBUTE_COMPRESSED          = 0x800
+	FILE_ATTRIBUTE_OFFLINE             = 0x1000
+	FILE_ATTRIBUTE_NOT_CONTENT_INDEXED = 0x2000
+	FILE_ATTRIBUTE_ENCRYPTED           = 0x4000
+
+	FILE_ATTRIBUTE_RECALL_ON_OPEN        = 0x40000
+	FILE_ATTRIBUTE_RECALL_ON_DATA_ACCESS = 0x400000
+)
+
+// File system flags for VolumeInformation.FileSystemFlags
+// https://docs.microsoft.com/en-us/windows/win32/api/fileapi/nf-fileapi-getvolumeinformationw
+const (
+	FILE_CASE_SENSITIVE_SEARCH = 0x1
+	FILE_CASE_PRESERVED_NAMES  = 0x2
+	FILE_UNICODE_ON_DISK       = 0x4
+	FILE_PERSISTENT_ACLS       = 0x8
+	FILE_NAMED_STREAMS         = 0x40000
+	FILE_READ_ONLY_VOLUME      = 0x80000
 )
 
 // ZwCreateFile options
@@ -60,6 +91,7 @@ const (
 	FILE_SEQUENTIAL_ONLY    = 0x04
 	FILE_NON_DIRECTORY_FILE = 0x40
 	FILE_DELETE_ON_CLOSE    = 0x000010
+	FILE_OPEN_REPARSE_POINT = 0x200000
 
 	// disposition
 	FILE_SUPERSEDE    = 0
@@ -79,6 +111,12 @@ const (
 	FILE_DELETE_CHILD     = 0x40
 	FILE_READ_ATTRIBUTES  = 0x80
 	FILE_WRITE_ATTRIBUTES = 0x100
+	DELETE                = 0x10000
+
+	// share
+	FILE_SHARE_READ   = 1
+	FILE_SHARE_WRITE  = 2
+	FILE_SHARE_DELETE = 4
 )
 
 const VOLUME_SECURITY_DESCRIPTOR_MAX_SIZE = (1024 * 16)
@@ -87,10 +125,19 @@ const MAX_PATH = 260
 type MountPointInfo struct {
 	Type         uint32
 	MountPoint   string
-	UNCName      string
+	UNCName      string // e.g. \\server\share
 	DeviceName   string
 	SessionID    uint32
-	MountOptions uint32
+	MountOptions MountFlags
+}
+
+// DriveLetter returns drive letter of the mount point. e.g. "X". Empty if mounted on a directory.
+func (mp *MountPointInfo) DriveLetter() string {
+	p := normalizeMountPoint(mp.MountPoint)
+	if len(p) != 2 || p[1] != ':' || p[0] < 'A' || p[0] > 'Z' {
+		return ""
+	}
+	return p[:1]
 }
 
 type nativeMountPointInfo struct {
@@ -191,6 +238,11 @@ type ByHandleFileInfo struct {
 	FileIndexLow       int32
 }
 
+type WIN32_FIND_STREAM_DATA struct {
+	StreamSize int64
+	StreamName [MAX_PATH + 36]uint16
+}
+
 type WIN32_FIND_DATAW struct {
 	FileAttributes    int32
 	CreationTime      FileTime
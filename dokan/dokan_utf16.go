@@ -0,0 +1,25 @@
+package dokan
+
+import (
+	"strings"
+	"syscall"
+	"unicode/utf16"
+)
+
+// UTF16FromString returns the UTF-16 encoding of s with a terminating NUL.
+// It returns syscall.EINVAL if s contains a NUL.
+func UTF16FromString(s string) ([]uint16, error) {
+	if strings.IndexByte(s, 0) >= 0 {
+		return nil, syscall.EINVAL
+	}
+	return utf16.Encode([]rune(s + "\x00")), nil
+}
+
+// UTF16PtrFromString returns pointer to the UTF-16 encoding of s with a terminating NUL.
+func UTF16PtrFromString(s string) (*uint16, error) {
+	a, err := UTF16FromString(s)
+	if err != nil {
+		return nil, err
+	}
+	return &a[0], nil
+}
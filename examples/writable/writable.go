@@ -53,6 +53,18 @@ func (fsys *writableDirFS) Rename(name, newName string) error {
 	return os.Rename(path.Join(fsys.path, name), path.Join(fsys.path, newName))
 }
 
+func (fsys *writableDirFS) Replace(name, newName string) error {
+	if !fs.ValidPath(name) || !fs.ValidPath(newName) {
+		return &fs.PathError{Op: "replace", Path: name, Err: fs.ErrInvalid}
+	}
+	// os.Rename replaces newName atomically on both Windows and Unix.
+	return os.Rename(path.Join(fsys.path, name), path.Join(fsys.path, newName))
+}
+
+func (fsys *writableDirFS) Statfs() (*dkango.FSStat, error) {
+	return dkango.DirStatfs(fsys.path)
+}
+
 func main() {
 	srcDir := "."
 	mountPoint := "X:"
@@ -3,6 +3,7 @@ package dkango
 import (
 	"io"
 	"io/fs"
+	"time"
 
 	"github.com/binzume/dkango/dokan"
 )
@@ -25,6 +26,13 @@ type RenameFS interface {
 	Rename(name string, newName string) error
 }
 
+// An interface to replace an existing file by renaming another file atomically.
+// If ReplaceFS is not implemented, Rename is used even if newName already exists.
+type ReplaceFS interface {
+	fs.FS
+	Replace(name string, newName string) error
+}
+
 // An interface to make new directories in the file system.
 type MkdirFS interface {
 	fs.FS
@@ -54,9 +62,58 @@ type DiskSpace struct {
 }
 
 type MountOptions struct {
+	// Zero fields of VolumeInfo are filled with the defaults. e.g. FileSystemName: "Dokan", MaximumComponentLength: 255
 	VolumeInfo    dokan.VolumeInformation
 	DiskSpaceFunc func() DiskSpace // optional
 	Flags         uint32
+
+	// Tuning parameters for Dokan. Zero values mean the defaults of Dokan.
+	Timeout                  time.Duration
+	AllocationUnitSize       uint32
+	SectorSize               uint32
+	UNCName                  string // \\server\share for network drive. FlagNetwork is enabled automatically.
+	SingleThread             bool
+	VolumeSecurityDescriptor []byte
+
+	// Preference of drive letters for AutoMountPoint. e.g. "XYZ" (default: DefaultDriveLetters)
+	DriveLetters string
+
+	// OnUnmount is called when the volume is unmounted. (optional)
+	// err is nil if unmounted by MountInfo.Close(), otherwise dokan.ErrUnmounted.
+	OnUnmount func(err error)
+
+	// Observer receives an event for each operation. (optional)
+	// Errors of the operations are logged if Observer is nil.
+	Observer dokan.Observer
+
+	// Middleware wraps the dokan.Disk of the FS. The first one is the outermost. (optional)
+	// e.g. func(next dokan.Disk) dokan.Disk { return dokan.NewRecorder(next, traceFile) }
+	Middleware []dokan.Middleware
+
+	// AccessPolicy is called for each CreateFile to allow or deny the request. (optional)
+	// e.g. Allow writing only from a specific application by AccessRequest.ImageName.
+	AccessPolicy func(req *AccessRequest) AccessDecision
+
+	// PathRules restricts access to the paths. (optional)
+	// Read-only paths are reported with FILE_ATTRIBUTE_READONLY.
+	PathRules PathRules
+
+	// AttributesFunc returns additional FILE_ATTRIBUTE_* for the file. (optional)
+	// e.g. HideDotFiles. info may be nil if it is not available.
+	AttributesFunc func(name string, info fs.FileInfo) uint32
+}
+
+func (opt *MountOptions) dokanOptions() *dokan.Options {
+	return &dokan.Options{
+		Flags:                    opt.Flags,
+		SingleThread:             opt.SingleThread,
+		Timeout:                  opt.Timeout,
+		AllocationUnitSize:       opt.AllocationUnitSize,
+		SectorSize:               opt.SectorSize,
+		UNCName:                  opt.UNCName,
+		VolumeSecurityDescriptor: opt.VolumeSecurityDescriptor,
+		Observer:                 opt.Observer,
+	}
 }
 
 const (
@@ -64,7 +121,7 @@ const (
 	FlagDebug = dokan.DOKAN_OPTION_DEBUG
 	// Output debug messages to stderr
 	FlagStderr = dokan.DOKAN_OPTION_STDERR
-	// Enable filename:streamname path
+	// Enable filename:streamname path. FILE_NAMED_STREAMS is reported if fsys implements StreamFS.
 	FlagAltStream = dokan.DOKAN_OPTION_ALT_STREAM
 	// Readonly FS even if fsys implements OpenWriterFS.
 	FlagsWriteProtect = dokan.DOKAN_OPTION_WRITE_PROTECT
@@ -77,15 +134,34 @@ const (
 // MountFS mounts fsys on mountPoint.
 //
 // mountPoint must be a valid unused drive letter or a directory on NTFS.
+// mountPoint can be empty to mount as a network drive without a drive letter if opt.UNCName is specified.
+// If mountPoint is AutoMountPoint ("*"), the first free drive letter in opt.DriveLetters is used.
+// MountInfo.MountPoint() returns the actual mount point.
 //
 // To provide random access, file opened by fsys should implement io.Seeker or ReaderAt and WriterAt.
 // If only sequential access is provided, many applications will not work properly.
 func MountFS(mountPoint string, fsys fs.FS, opt *MountOptions) (*dokan.MountInfo, error) {
 	if opt == nil {
 		opt = &MountOptions{
-			VolumeInfo: dokan.VolumeInformation{Name: "", FileSystemName: "Dokan"},
-			Flags:      dokan.DOKAN_OPTION_ALT_STREAM,
+			Flags: dokan.DOKAN_OPTION_ALT_STREAM,
 		}
 	}
-	return dokan.MountDisk(mountPoint, &disk{opt: opt, fsys: fsys}, opt.Flags)
+	if mountPoint == AutoMountPoint {
+		letter, err := FreeDriveLetter(opt.DriveLetters)
+		if err != nil {
+			return nil, err
+		}
+		mountPoint = letter
+	}
+	mi, err := dokan.MountDiskWithOptions(mountPoint, dokan.Chain(&disk{opt: opt, fsys: fsys}, opt.Middleware...), opt.dokanOptions())
+	if err != nil {
+		return nil, err
+	}
+	if opt.OnUnmount != nil {
+		go func() {
+			<-mi.Done()
+			opt.OnUnmount(mi.Err())
+		}()
+	}
+	return mi, nil
 }
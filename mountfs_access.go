@@ -0,0 +1,122 @@
+package dkango
+
+import (
+	"sync"
+	"time"
+
+	"github.com/binzume/dkango/dokan"
+)
+
+// AccessDecision is a result of AccessPolicy.
+type AccessDecision int
+
+const (
+	AccessAllow AccessDecision = iota
+	// Deny requests to modify the file.
+	AccessReadOnly
+	AccessDeny
+)
+
+func (d AccessDecision) String() string {
+	switch d {
+	case AccessAllow:
+		return "Allow"
+	case AccessReadOnly:
+		return "ReadOnly"
+	case AccessDeny:
+		return "Deny"
+	}
+	return "Unknown"
+}
+
+// AccessRequest is passed to MountOptions.AccessPolicy for each CreateFile.
+// Fields of Requestor may be empty if the process or the token is not accessible.
+type AccessRequest struct {
+	dokan.Requestor
+	Path        string // path in the FS. e.g. "dir/file.txt"
+	Access      uint32 // desired access. e.g. dokan.FILE_READ_DATA
+	Disposition uint32 // e.g. dokan.FILE_OPEN
+}
+
+// getRequestor is replaced in tests.
+var getRequestor = dokan.GetRequestor
+
+// Requestors are cached by the process ID to avoid querying the process and the token for every CreateFile.
+const requestorCacheDuration = 5 * time.Second
+
+type requestorCache struct {
+	lock    sync.Mutex
+	entries map[uint32]requestorCacheEntry
+}
+
+type requestorCacheEntry struct {
+	requestor dokan.Requestor
+	expires   time.Time
+}
+
+// get returns the requestor of the request. Failed lookups are not cached.
+func (c *requestorCache) get(finfo *dokan.FileInfo) dokan.Requestor {
+	now := time.Now()
+	c.lock.Lock()
+	if e, ok := c.entries[finfo.ProcessId]; ok && now.Before(e.expires) {
+		c.lock.Unlock()
+		return e.requestor
+	}
+	c.lock.Unlock()
+
+	r, err := getRequestor(finfo)
+	if r == nil {
+		return dokan.Requestor{ProcessID: finfo.ProcessId}
+	}
+	if err == nil {
+		c.lock.Lock()
+		if c.entries == nil {
+			c.entries = map[uint32]requestorCacheEntry{}
+		}
+		for pid, e := range c.entries {
+			if now.After(e.expires) {
+				delete(c.entries, pid)
+			}
+		}
+		c.entries[finfo.ProcessId] = requestorCacheEntry{requestor: *r, expires: now.Add(requestorCacheDuration)}
+		c.lock.Unlock()
+	}
+	return *r
+}
+
+// checkAccess evaluates PathRules and AccessPolicy for the request. The stricter decision is returned.
+func (d *disk) checkAccess(name string, access, disposition uint32, finfo *dokan.FileInfo) AccessDecision {
+	decision, _ := d.opt.PathRules.Match(name)
+	if decision == AccessDeny || d.opt.AccessPolicy == nil {
+		return decision
+	}
+	req := &AccessRequest{Requestor: d.requestors.get(finfo), Path: name, Access: access, Disposition: disposition}
+	if d := d.opt.AccessPolicy(req); d > decision {
+		decision = d
+	}
+	return decision
+}
+
+// checkTargetAccess evaluates the new name of MoveFile and CreateHardLink as CreateFile creating the file.
+// The target is always modified, so only AccessAllow permits the request.
+func (d *disk) checkTargetAccess(newname string, access uint32, replaceIfExisting bool, finfo *dokan.FileInfo) AccessDecision {
+	disposition := uint32(dokan.FILE_CREATE)
+	if replaceIfExisting {
+		disposition = dokan.FILE_SUPERSEDE
+	}
+	return d.checkAccess(newname, access, disposition, finfo)
+}
+
+// isModifyRequest returns true if CreateFile with the parameters may modify the file.
+func isModifyRequest(access, disposition, options uint32, exists bool) bool {
+	if access&(writeAccess|deleteAccess|dokan.FILE_WRITE_ATTRIBUTES|dokan.FILE_WRITE_EA) != 0 || options&dokan.FILE_DELETE_ON_CLOSE != 0 {
+		return true
+	}
+	switch disposition {
+	case dokan.FILE_SUPERSEDE, dokan.FILE_OVERWRITE, dokan.FILE_OVERWRITE_IF, dokan.FILE_CREATE:
+		return true
+	case dokan.FILE_OPEN_IF:
+		return !exists
+	}
+	return false
+}
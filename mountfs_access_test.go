@@ -0,0 +1,127 @@
+package dkango
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/binzume/dkango/dokan"
+)
+
+func TestAccessPolicy(t *testing.T) {
+	getRequestor = func(finfo *dokan.FileInfo) (*dokan.Requestor, error) {
+		images := map[uint32]string{1: `C:\sync\client.exe`, 2: `C:\Windows\explorer.exe`}
+		return &dokan.Requestor{ProcessID: finfo.ProcessId, ImageName: images[finfo.ProcessId], UserSID: "S-1-5-18"}, nil
+	}
+	defer func() { getRequestor = dokan.GetRequestor }()
+
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "a.txt"), []byte("aaa"), 0666)
+	var requests []AccessRequest
+	d := &disk{opt: &MountOptions{AccessPolicy: func(req *AccessRequest) AccessDecision {
+		requests = append(requests, *req)
+		if strings.HasSuffix(req.ImageName, `\client.exe`) {
+			return AccessAllow
+		} else if req.ImageName != "" {
+			return AccessReadOnly
+		}
+		return AccessDeny
+	}}, fsys: &testWritableFs{FS: os.DirFS(dir), path: dir}}
+
+	share := uint32(dokan.FILE_SHARE_READ | dokan.FILE_SHARE_WRITE | dokan.FILE_SHARE_DELETE)
+	tests := []struct {
+		pid         uint32
+		name        string
+		access      uint32
+		disposition uint32
+		expected    dokan.NTStatus
+	}{
+		{1, "/a.txt", dokan.FILE_WRITE_DATA, dokan.FILE_OPEN, dokan.STATUS_SUCCESS},
+		{1, "/b.txt", dokan.FILE_WRITE_DATA, dokan.FILE_CREATE, dokan.STATUS_SUCCESS},
+		{2, "/a.txt", dokan.FILE_READ_DATA, dokan.FILE_OPEN, dokan.STATUS_SUCCESS},
+		{2, "/a.txt", dokan.FILE_READ_DATA, dokan.FILE_OPEN_IF, dokan.STATUS_SUCCESS},
+		{2, "/a.txt", dokan.FILE_WRITE_DATA, dokan.FILE_OPEN, dokan.STATUS_ACCESS_DENIED},
+		{2, "/a.txt", dokan.DELETE, dokan.FILE_OPEN, dokan.STATUS_ACCESS_DENIED},
+		{2, "/a.txt", dokan.FILE_READ_DATA, dokan.FILE_OVERWRITE_IF, dokan.STATUS_ACCESS_DENIED},
+		{2, "/c.txt", dokan.FILE_READ_DATA, dokan.FILE_OPEN_IF, dokan.STATUS_ACCESS_DENIED},
+		{3, "/a.txt", dokan.FILE_READ_DATA, dokan.FILE_OPEN, dokan.STATUS_ACCESS_DENIED},
+	}
+	for _, tt := range tests {
+		finfo := &dokan.FileInfo{ProcessId: tt.pid}
+		f, status := d.CreateFile(tt.name, 0, tt.access, 0, share, tt.disposition, 0, finfo)
+		if status != tt.expected {
+			t.Errorf("CreateFile(%v, %x, %v) by %v: %x, expected %x", tt.name, tt.access, tt.disposition, tt.pid, status, tt.expected)
+		}
+		if f != nil {
+			f.Cleanup(finfo)
+			f.CloseFile(finfo)
+		}
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, "c.txt")); err == nil {
+		t.Error("c.txt should not be created")
+	}
+	if len(requests) != len(tests) {
+		t.Fatal("unexpected requests: ", len(requests))
+	}
+	if r := requests[0]; r.Path != "a.txt" || r.ProcessID != 1 || r.ImageName != `C:\sync\client.exe` || r.UserSID != "S-1-5-18" || r.Access != dokan.FILE_WRITE_DATA {
+		t.Error("unexpected request: ", r)
+	}
+}
+
+func TestAccessPolicy_MoveFile(t *testing.T) {
+	dir := t.TempDir()
+	os.Mkdir(filepath.Join(dir, "secret"), 0777)
+	os.WriteFile(filepath.Join(dir, "a.txt"), []byte("aaa"), 0666)
+	var targets []AccessRequest
+	d := &disk{opt: &MountOptions{AccessPolicy: func(req *AccessRequest) AccessDecision {
+		if req.Path == "a.txt" {
+			return AccessAllow
+		}
+		targets = append(targets, *req)
+		if strings.HasPrefix(strings.ToLower(req.Path), "secret/") {
+			return AccessReadOnly
+		}
+		return AccessAllow
+	}}, fsys: &testWritableFs{FS: os.DirFS(dir), path: dir}}
+
+	f := openTestFile(t, d, "/a.txt", dokan.DELETE, dokan.FILE_OPEN, 0)
+	defer f.CloseFile(&dokan.FileInfo{})
+	if status := f.MoveFile("/Secret/a.txt", false, &dokan.FileInfo{}); status != dokan.STATUS_ACCESS_DENIED {
+		t.Errorf("moving a file to read-only path should be denied: %x", status)
+	}
+	if status := f.MoveFile("/b.txt", true, &dokan.FileInfo{}); status != dokan.STATUS_SUCCESS {
+		t.Errorf("MoveFile() error: %x", status)
+	}
+	if len(targets) != 2 || targets[0].Disposition != dokan.FILE_CREATE || targets[1].Disposition != dokan.FILE_SUPERSEDE || targets[1].Access != dokan.DELETE {
+		t.Error("unexpected requests: ", targets)
+	}
+}
+
+func TestRequestorCache(t *testing.T) {
+	calls := 0
+	getRequestor = func(finfo *dokan.FileInfo) (*dokan.Requestor, error) {
+		calls++
+		return &dokan.Requestor{ProcessID: finfo.ProcessId, ImageName: `C:\app.exe`}, nil
+	}
+	defer func() { getRequestor = dokan.GetRequestor }()
+
+	var c requestorCache
+	for i := 0; i < 3; i++ {
+		if r := c.get(&dokan.FileInfo{ProcessId: 1}); r.ImageName != `C:\app.exe` {
+			t.Error("unexpected requestor: ", r)
+		}
+	}
+	c.get(&dokan.FileInfo{ProcessId: 2})
+	if calls != 2 {
+		t.Error("requestor should be cached by process ID: ", calls)
+	}
+
+	c.entries[1] = requestorCacheEntry{expires: time.Now().Add(-time.Second)}
+	c.get(&dokan.FileInfo{ProcessId: 1})
+	if calls != 3 {
+		t.Error("expired requestor should be queried again: ", calls)
+	}
+}
@@ -0,0 +1,57 @@
+package dkango
+
+import (
+	"io/fs"
+
+	"github.com/binzume/dkango/dokan"
+)
+
+// FallocateFile is an optional interface for the writers returned by OpenWriter to preallocate the disk space.
+// The file size must not be changed by Fallocate.
+type FallocateFile interface {
+	Fallocate(size int64) error
+}
+
+// FallocateFS is an optional interface to preallocate the disk space for the file.
+// The file size must not be changed by Fallocate.
+type FallocateFS interface {
+	fs.FS
+	Fallocate(name string, size int64) error
+}
+
+// SetAllocationSize implements dokan.AllocationSizeHandle.
+// The file is truncated if size is smaller than the file size. Otherwise the file size is not changed.
+// NOTE: Reporting the allocation size is not supported. Dokan derives it from the file size
+// because ByHandleFileInfo and WIN32_FIND_DATAW have no field for it, so the preallocated size is not visible from Windows.
+func (f *openedFile) SetAllocationSize(size int64, finfo *dokan.FileInfo) dokan.NTStatus {
+	if f.access&writeAccess == 0 || f.readOnly {
+		return dokan.STATUS_ACCESS_DENIED
+	}
+	ctx, cancel := dokan.NewRequestContext(finfo)
+	defer cancel()
+	stat, err := statContext(ctx, f.mi.fsys, f.name)
+	if err != nil {
+		return dokan.ErrorToNTStatus(err)
+	}
+	fileSize := stat.Size()
+	if f.pos > fileSize {
+		fileSize = f.pos // written data may not be visible from Stat() yet
+	}
+	if size < fileSize {
+		return f.SetEndOfFile(size, finfo)
+	}
+	if f.state == nil || f.mi.files.allocated(f.state) >= size {
+		return dokan.STATUS_SUCCESS
+	}
+
+	if fa, ok := f.file.(FallocateFile); ok {
+		err = fa.Fallocate(size)
+	} else if fsys, ok := asFS[FallocateFS](f.mi.fsys); ok {
+		err = fsys.Fallocate(f.name, size)
+	}
+	if err != nil {
+		return dokan.ErrorToNTStatus(err)
+	}
+	f.mi.files.setAllocated(f.state, size)
+	return dokan.STATUS_SUCCESS
+}
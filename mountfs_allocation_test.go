@@ -0,0 +1,69 @@
+package dkango
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/binzume/dkango/dokan"
+)
+
+type testFallocateFs struct {
+	testWritableFs
+	allocated []int64
+}
+
+func (fsys *testFallocateFs) Fallocate(name string, size int64) error {
+	fsys.allocated = append(fsys.allocated, size)
+	return nil
+}
+
+func TestSetAllocationSize(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "a.txt"), []byte("0123456789"), 0666)
+	fsys := &testFallocateFs{testWritableFs: testWritableFs{FS: os.DirFS(dir), path: dir}}
+	d := &disk{opt: &MountOptions{}, fsys: fsys}
+	size := func() int64 {
+		st, _ := os.Stat(filepath.Join(dir, "a.txt"))
+		return st.Size()
+	}
+
+	f := openTestFile(t, d, "/a.txt", dokan.FILE_READ_DATA, dokan.FILE_OPEN, 0)
+	if status := f.SetAllocationSize(100, &dokan.FileInfo{}); status != dokan.STATUS_ACCESS_DENIED {
+		t.Errorf("SetAllocationSize() without write access should fail: %x", status)
+	}
+	f.CloseFile(&dokan.FileInfo{})
+
+	f = openTestFile(t, d, "/a.txt", dokan.FILE_WRITE_DATA, dokan.FILE_OPEN, 0)
+	defer f.CloseFile(&dokan.FileInfo{})
+	if status := f.SetAllocationSize(1<<20, &dokan.FileInfo{}); status != dokan.STATUS_SUCCESS {
+		t.Fatalf("SetAllocationSize() error: %x", status)
+	}
+	f.SetAllocationSize(4096, &dokan.FileInfo{}) // already allocated
+	if size() != 10 {
+		t.Error("file size should not be changed: ", size())
+	}
+	if len(fsys.allocated) != 1 || fsys.allocated[0] != 1<<20 {
+		t.Error("Fallocate() should be called once: ", fsys.allocated)
+	}
+
+	if status := f.SetAllocationSize(4, &dokan.FileInfo{}); status != dokan.STATUS_SUCCESS || size() != 4 {
+		t.Errorf("file should be truncated: %x %v", status, size())
+	}
+	f.SetAllocationSize(4096, &dokan.FileInfo{})
+	if len(fsys.allocated) != 2 || fsys.allocated[1] != 4096 {
+		t.Error("Fallocate() should be called after truncation: ", fsys.allocated)
+	}
+
+	f.CloseFile(&dokan.FileInfo{})
+
+	// size of the file written after open
+	f = openTestFile(t, d, "/a.txt", dokan.FILE_WRITE_DATA, dokan.FILE_OPEN, 0)
+	var n int32
+	if status := f.WriteFile([]byte("0123456789"), &n, 4, &dokan.FileInfo{}); status != dokan.STATUS_SUCCESS {
+		t.Fatalf("WriteFile() error: %x", status)
+	}
+	if status := f.SetAllocationSize(8, &dokan.FileInfo{}); status != dokan.STATUS_SUCCESS || size() != 8 {
+		t.Errorf("file should be truncated: %x %v", status, size())
+	}
+}
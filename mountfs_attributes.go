@@ -0,0 +1,89 @@
+package dkango
+
+import (
+	"io/fs"
+	"path"
+	"strings"
+
+	"github.com/binzume/dkango/dokan"
+)
+
+// AttributesFileInfo is an optional interface for fs.FileInfo to report Windows file attributes.
+type AttributesFileInfo interface {
+	fs.FileInfo
+	// FILE_ATTRIBUTE_* e.g. dokan.FILE_ATTRIBUTE_HIDDEN
+	FileAttributes() uint32
+}
+
+// PlaceholderFileInfo is an optional interface for fs.FileInfo of the files whose content is not available locally.
+// Placeholders are reported with FILE_ATTRIBUTE_OFFLINE and FILE_ATTRIBUTE_RECALL_ON_DATA_ACCESS.
+// Explorer shows them as "available online only" and doesn't read them to generate thumbnails.
+type PlaceholderFileInfo interface {
+	fs.FileInfo
+	IsPlaceholder() bool
+}
+
+// AttributesFS is an optional interface for FS to report Windows file attributes.
+type AttributesFS interface {
+	fs.FS
+	// FILE_ATTRIBUTE_* e.g. dokan.FILE_ATTRIBUTE_HIDDEN
+	FileAttributes(name string) (uint32, error)
+}
+
+// Attributes which can be reported by backends.
+// FILE_ATTRIBUTE_DIRECTORY is determined by fs.FileInfo.IsDir().
+const reportableAttributes = dokan.FILE_ATTRIBUTE_READONLY | dokan.FILE_ATTRIBUTE_HIDDEN | dokan.FILE_ATTRIBUTE_SYSTEM |
+	dokan.FILE_ATTRIBUTE_ARCHIVE | dokan.FILE_ATTRIBUTE_TEMPORARY | dokan.FILE_ATTRIBUTE_SPARSE_FILE | dokan.FILE_ATTRIBUTE_REPARSE_POINT |
+	dokan.FILE_ATTRIBUTE_OFFLINE | dokan.FILE_ATTRIBUTE_NOT_CONTENT_INDEXED | dokan.FILE_ATTRIBUTE_RECALL_ON_DATA_ACCESS
+
+// HideDotFiles is an AttributesFunc which hides files whose name starts with ".".
+func HideDotFiles(name string, fi fs.FileInfo) uint32 {
+	if base := path.Base(name); strings.HasPrefix(base, ".") && base != "." && base != ".." {
+		return dokan.FILE_ATTRIBUTE_HIDDEN
+	}
+	return 0
+}
+
+// fileAttributes returns FILE_ATTRIBUTE_* for the file. info may be nil.
+// isDir is true for the directory or the symbolic link to a directory.
+func (d *disk) fileAttributes(name string, isDir bool, info fs.FileInfo, readOnly bool) int32 {
+	var attrs uint32
+	if info != nil {
+		isDir = isDir || info.IsDir()
+		if info.Mode()&0o200 == 0 {
+			attrs |= dokan.FILE_ATTRIBUTE_READONLY
+		}
+		if isSymlink(info) {
+			attrs |= dokan.FILE_ATTRIBUTE_REPARSE_POINT
+		}
+		attrs |= sysFileAttributes(info)
+		if info, ok := info.(AttributesFileInfo); ok {
+			attrs |= info.FileAttributes()
+		}
+		if info, ok := info.(SparseFileInfo); ok && info.IsSparse() {
+			attrs |= dokan.FILE_ATTRIBUTE_SPARSE_FILE
+		}
+		if info, ok := info.(PlaceholderFileInfo); ok && info.IsPlaceholder() {
+			attrs |= dokan.FILE_ATTRIBUTE_OFFLINE | dokan.FILE_ATTRIBUTE_RECALL_ON_DATA_ACCESS
+		}
+	}
+	if fsys, ok := asFS[AttributesFS](d.fsys); ok {
+		if a, err := fsys.FileAttributes(name); err == nil {
+			attrs |= a
+		}
+	}
+	if d.opt.AttributesFunc != nil {
+		attrs |= d.opt.AttributesFunc(name, info)
+	}
+	if readOnly {
+		attrs |= dokan.FILE_ATTRIBUTE_READONLY
+	}
+	attrs &= reportableAttributes
+	if isDir {
+		attrs |= dokan.FILE_ATTRIBUTE_DIRECTORY
+	}
+	if attrs == 0 {
+		attrs = dokan.FILE_ATTRIBUTE_NORMAL
+	}
+	return int32(attrs)
+}
@@ -0,0 +1,12 @@
+//go:build !windows
+// +build !windows
+
+package dkango
+
+import (
+	"io/fs"
+)
+
+func sysFileAttributes(info fs.FileInfo) uint32 {
+	return 0
+}
@@ -0,0 +1,81 @@
+package dkango
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/binzume/dkango/dokan"
+)
+
+type testAttributesFs struct {
+	fs.FS
+}
+
+func (fsys *testAttributesFs) FileAttributes(name string) (uint32, error) {
+	if name == "sys.txt" {
+		return dokan.FILE_ATTRIBUTE_SYSTEM | dokan.FILE_ATTRIBUTE_DIRECTORY, nil
+	}
+	return 0, fs.ErrNotExist
+}
+
+type testAttributesInfo struct {
+	fs.FileInfo
+}
+
+func (fi *testAttributesInfo) FileAttributes() uint32 {
+	return dokan.FILE_ATTRIBUTE_OFFLINE | dokan.FILE_ATTRIBUTE_NORMAL
+}
+
+func TestFileAttributes(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, ".hidden"), []byte("a"), 0666)
+	os.WriteFile(filepath.Join(dir, "sys.txt"), []byte("b"), 0666)
+	os.WriteFile(filepath.Join(dir, "normal.txt"), []byte("c"), 0666)
+	d := &disk{opt: &MountOptions{AttributesFunc: HideDotFiles}, fsys: &testAttributesFs{FS: os.DirFS(dir)}}
+
+	root := openTestFile(t, d, "/", dokan.FILE_READ_DATA, dokan.FILE_OPEN, 0)
+	defer root.CloseFile(&dokan.FileInfo{})
+	attrs := map[string]int32{}
+	root.FindFiles(func(fi *dokan.WIN32_FIND_DATAW) (bool, error) {
+		attrs[findDataName(fi)] = fi.FileAttributes
+		return false, nil
+	}, &dokan.FileInfo{})
+
+	expected := map[string]int32{
+		".hidden":    dokan.FILE_ATTRIBUTE_HIDDEN,
+		"sys.txt":    dokan.FILE_ATTRIBUTE_SYSTEM,
+		"normal.txt": dokan.FILE_ATTRIBUTE_NORMAL,
+	}
+	for name, a := range expected {
+		if attrs[name] != a {
+			t.Errorf("FindFiles: attributes of %v: %x, expected %x", name, attrs[name], a)
+		}
+		f := openTestFile(t, d, "/"+name, dokan.FILE_READ_DATA, dokan.FILE_OPEN, 0)
+		var fi dokan.ByHandleFileInfo
+		f.GetFileInformation(&fi, &dokan.FileInfo{})
+		if fi.FileAttributes != a {
+			t.Errorf("GetFileInformation: attributes of %v: %x, expected %x", name, fi.FileAttributes, a)
+		}
+		f.CloseFile(&dokan.FileInfo{})
+	}
+
+	mfs := fstest.MapFS{"a.txt": &fstest.MapFile{Mode: 0666}, "dir": &fstest.MapFile{Mode: fs.ModeDir | 0777}}
+	d = &disk{opt: &MountOptions{}, fsys: mfs}
+	info, _ := fs.Stat(mfs, "a.txt")
+	if a := d.fileAttributes("a.txt", false, &testAttributesInfo{info}, false); a != dokan.FILE_ATTRIBUTE_OFFLINE {
+		t.Errorf("unexpected attributes: %x", a)
+	}
+	if a := d.fileAttributes("a.txt", false, &testAttributesInfo{info}, true); a != dokan.FILE_ATTRIBUTE_OFFLINE|dokan.FILE_ATTRIBUTE_READONLY {
+		t.Errorf("unexpected attributes: %x", a)
+	}
+	info, _ = fs.Stat(mfs, "dir")
+	if a := d.fileAttributes("dir", false, info, true); a != dokan.FILE_ATTRIBUTE_DIRECTORY|dokan.FILE_ATTRIBUTE_READONLY {
+		t.Errorf("unexpected attributes: %x", a)
+	}
+	if a := d.fileAttributes("dir", true, nil, false); a != dokan.FILE_ATTRIBUTE_DIRECTORY {
+		t.Errorf("unexpected attributes: %x", a)
+	}
+}
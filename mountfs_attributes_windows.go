@@ -0,0 +1,17 @@
+//go:build windows
+// +build windows
+
+package dkango
+
+import (
+	"io/fs"
+	"syscall"
+)
+
+// sysFileAttributes returns attributes of the files in os.DirFS.
+func sysFileAttributes(info fs.FileInfo) uint32 {
+	if sys, ok := info.Sys().(*syscall.Win32FileAttributeData); ok {
+		return sys.FileAttributes
+	}
+	return 0
+}
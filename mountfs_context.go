@@ -0,0 +1,250 @@
+package dkango
+
+import (
+	"context"
+	"io"
+	"io/fs"
+
+	"github.com/binzume/dkango/dokan"
+)
+
+// Context-aware variants of the interfaces.
+// The context is canceled when the request timed out or the file system is unmounted.
+// If the FS implements both, context-aware variant is preferred.
+//
+// Files and writers returned by OpenContext/OpenWriterContext are used after the request is completed,
+// so they should not depend on the context.
+
+// An interface for opening files with the request context. (context-aware variant of fs.FS)
+type OpenContextFS interface {
+	fs.FS
+	OpenContext(ctx context.Context, name string) (fs.File, error)
+}
+
+// An interface to get file info with the request context. (context-aware variant of fs.StatFS)
+type StatContextFS interface {
+	fs.FS
+	StatContext(ctx context.Context, name string) (fs.FileInfo, error)
+}
+
+// An interface to read directories with the request context. (context-aware variant of fs.ReadDirFS)
+type ReadDirContextFS interface {
+	fs.FS
+	ReadDirContext(ctx context.Context, name string) ([]fs.DirEntry, error)
+}
+
+// An interface for opening ReadDirFile with the request context. (context-aware variant of OpenDirFS)
+type OpenDirContextFS interface {
+	fs.FS
+	OpenDirContext(ctx context.Context, name string) (fs.ReadDirFile, error)
+}
+
+// An interface for opening files for writing with the request context. (context-aware variant of OpenWriterFS)
+type OpenWriterContextFS interface {
+	fs.FS
+	OpenWriterContext(ctx context.Context, name string, flag int) (io.WriteCloser, error)
+}
+
+// An interface to remove file or directory with the request context. (context-aware variant of RemoveFS)
+type RemoveContextFS interface {
+	fs.FS
+	RemoveContext(ctx context.Context, name string) error
+}
+
+// An interface to rename file or directory with the request context. (context-aware variant of RenameFS)
+type RenameContextFS interface {
+	fs.FS
+	RenameContext(ctx context.Context, name string, newName string) error
+}
+
+// An interface to replace an existing file with the request context. (context-aware variant of ReplaceFS)
+type ReplaceContextFS interface {
+	fs.FS
+	ReplaceContext(ctx context.Context, name string, newName string) error
+}
+
+// An interface to make new directories with the request context. (context-aware variant of MkdirFS)
+type MkdirContextFS interface {
+	fs.FS
+	MkdirContext(ctx context.Context, name string, mode fs.FileMode) error
+}
+
+// An interface to truncate file with the request context. (context-aware variant of TruncateFS)
+type TruncateContextFS interface {
+	fs.FS
+	TruncateContext(ctx context.Context, name string, size int64) error
+}
+
+// HydrateFS is an optional interface to fetch the content of the file before it is accessed.
+// Hydrate is called once per handle before the content is read, or before the file is opened for writing without truncation.
+// Attribute-only queries (GetFileInformation, FindFiles) never call Hydrate nor open the content if the FS implements fs.StatFS.
+// Call ReportProgress(ctx) while downloading large files.
+type HydrateFS interface {
+	fs.FS
+	Hydrate(ctx context.Context, name string) error
+}
+
+// UnwrapFS is an optional interface for the FS wrapping another FS (e.g. QuotaFS).
+// Optional interfaces not implemented by the wrapper (e.g. ReadLinkFS, HydrateFS) are looked up in the wrapped FS.
+// The wrapper is writable, removable or renamable only if the wrapped FS is.
+type UnwrapFS interface {
+	fs.FS
+	Unwrap() fs.FS
+}
+
+// asFS returns fsys or the FS wrapped by it which implements T.
+func asFS[T fs.FS](fsys fs.FS) (T, bool) {
+	for {
+		if t, ok := fsys.(T); ok {
+			return t, true
+		}
+		u, ok := fsys.(UnwrapFS)
+		if !ok {
+			var zero T
+			return zero, false
+		}
+		fsys = u.Unwrap()
+	}
+}
+
+// ReportProgress notifies that a long operation is still making progress.
+// Backends can call this with the context passed to the *Context methods to prevent the request from timing out.
+func ReportProgress(ctx context.Context) {
+	dokan.ReportProgress(ctx)
+}
+
+func openContext(ctx context.Context, fsys fs.FS, name string) (fs.File, error) {
+	if cfs, ok := fsys.(OpenContextFS); ok {
+		return cfs.OpenContext(ctx, name)
+	}
+	return fsys.Open(name)
+}
+
+func statContext(ctx context.Context, fsys fs.FS, name string) (fs.FileInfo, error) {
+	if cfs, ok := fsys.(StatContextFS); ok {
+		return cfs.StatContext(ctx, name)
+	}
+	if _, ok := fsys.(fs.StatFS); !ok {
+		if cfs, ok := fsys.(OpenContextFS); ok {
+			f, err := cfs.OpenContext(ctx, name)
+			if err != nil {
+				return nil, err
+			}
+			defer f.Close()
+			return f.Stat()
+		}
+	}
+	return fs.Stat(fsys, name)
+}
+
+func readDirContext(ctx context.Context, fsys fs.FS, name string) ([]fs.DirEntry, error) {
+	if cfs, ok := fsys.(ReadDirContextFS); ok {
+		return cfs.ReadDirContext(ctx, name)
+	}
+	return fs.ReadDir(fsys, name)
+}
+
+// openDirContext returns nil if fsys implements neither OpenDirFS nor OpenDirContextFS.
+func openDirContext(ctx context.Context, fsys fs.FS, name string) (fs.ReadDirFile, error) {
+	if cfs, ok := fsys.(OpenDirContextFS); ok {
+		return cfs.OpenDirContext(ctx, name)
+	} else if fsys, ok := fsys.(OpenDirFS); ok {
+		return fsys.OpenDir(name)
+	}
+	return nil, nil
+}
+
+func isWritable(fsys fs.FS) bool {
+	switch fsys.(type) {
+	case OpenWriterContextFS, OpenWriterFS:
+		if u, ok := fsys.(UnwrapFS); ok {
+			return isWritable(u.Unwrap())
+		}
+		return true
+	}
+	return false
+}
+
+func openWriterContext(ctx context.Context, fsys fs.FS, name string, flag int) (io.WriteCloser, error) {
+	if cfs, ok := fsys.(OpenWriterContextFS); ok {
+		return cfs.OpenWriterContext(ctx, name, flag)
+	} else if fsys, ok := fsys.(OpenWriterFS); ok {
+		return fsys.OpenWriter(name, flag)
+	}
+	return nil, dokan.ErrNotSupported
+}
+
+func isRemovable(fsys fs.FS) bool {
+	switch fsys.(type) {
+	case RemoveContextFS, RemoveFS:
+		if u, ok := fsys.(UnwrapFS); ok {
+			return isRemovable(u.Unwrap())
+		}
+		return true
+	}
+	return false
+}
+
+func removeContext(ctx context.Context, fsys fs.FS, name string) error {
+	if cfs, ok := fsys.(RemoveContextFS); ok {
+		return cfs.RemoveContext(ctx, name)
+	} else if fsys, ok := fsys.(RemoveFS); ok {
+		return fsys.Remove(name)
+	}
+	return dokan.ErrNotSupported
+}
+
+func isRenamable(fsys fs.FS) bool {
+	switch fsys.(type) {
+	case RenameContextFS, RenameFS:
+		if u, ok := fsys.(UnwrapFS); ok {
+			return isRenamable(u.Unwrap())
+		}
+		return true
+	}
+	return false
+}
+
+func renameContext(ctx context.Context, fsys fs.FS, name, newName string) error {
+	if cfs, ok := fsys.(RenameContextFS); ok {
+		return cfs.RenameContext(ctx, name, newName)
+	} else if fsys, ok := fsys.(RenameFS); ok {
+		return fsys.Rename(name, newName)
+	}
+	return dokan.ErrNotSupported
+}
+
+// replaceContext falls back to renameContext if fsys implements neither ReplaceFS nor ReplaceContextFS.
+func replaceContext(ctx context.Context, fsys fs.FS, name, newName string) error {
+	if cfs, ok := fsys.(ReplaceContextFS); ok {
+		return cfs.ReplaceContext(ctx, name, newName)
+	} else if fsys, ok := fsys.(ReplaceFS); ok {
+		return fsys.Replace(name, newName)
+	}
+	return renameContext(ctx, fsys, name, newName)
+}
+
+func mkdirContext(ctx context.Context, fsys fs.FS, name string, mode fs.FileMode) error {
+	if cfs, ok := fsys.(MkdirContextFS); ok {
+		return cfs.MkdirContext(ctx, name, mode)
+	} else if fsys, ok := fsys.(MkdirFS); ok {
+		return fsys.Mkdir(name, mode)
+	}
+	return dokan.ErrNotSupported
+}
+
+func truncateContext(ctx context.Context, fsys fs.FS, name string, size int64) error {
+	if cfs, ok := fsys.(TruncateContextFS); ok {
+		return cfs.TruncateContext(ctx, name, size)
+	} else if fsys, ok := fsys.(TruncateFS); ok {
+		return fsys.Truncate(name, size)
+	}
+	return dokan.ErrNotSupported
+}
+
+func hydrate(ctx context.Context, fsys fs.FS, name string) error {
+	if fsys, ok := asFS[HydrateFS](fsys); ok {
+		return fsys.Hydrate(ctx, name)
+	}
+	return nil
+}
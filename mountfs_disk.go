@@ -6,35 +6,54 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/binzume/dkango/dokan"
 )
 
 type disk struct {
-	opt  *MountOptions
-	fsys fs.FS
+	opt   *MountOptions
+	fsys  fs.FS
+	files fileStates
+
+	requestors requestorCache
+
+	statfsMu    sync.Mutex
+	statfsCache *FSStat
+	statfsTime  time.Time
 }
 
 func (d *disk) GetVolumeInformation(finfo *dokan.FileInfo) (dokan.VolumeInformation, dokan.NTStatus) {
-	return d.opt.VolumeInfo, dokan.STATUS_SUCCESS
+	return d.volumeInformation(), dokan.STATUS_SUCCESS
 }
 
-func (d *disk) GetDiskFreeSpace(availableBytes *uint64, totalBytes *uint64, freeBytes *uint64, finfo *dokan.FileInfo) dokan.NTStatus {
-	if (d.opt.DiskSpaceFunc) != nil {
-		space := d.opt.DiskSpaceFunc()
-		*availableBytes = space.FreeBytesAvailable
-		*totalBytes = space.TotalNumberOfBytes
-		*freeBytes = space.TotalNumberOfFreeBytes
-		return dokan.STATUS_SUCCESS
+// volumeInformation fills zero fields of MountOptions.VolumeInfo with the defaults derived from fsys and the mount flags.
+// FILE_READ_ONLY_VOLUME is always set if fsys is not writable or the volume is write-protected.
+func (d *disk) volumeInformation() dokan.VolumeInformation {
+	vi := d.opt.VolumeInfo
+	if vi.FileSystemName == "" {
+		vi.FileSystemName = "Dokan"
+	}
+	if vi.MaximumComponentLength == 0 {
+		vi.MaximumComponentLength = 255
+	}
+	if vi.FileSystemFlags == 0 {
+		vi.FileSystemFlags = dokan.FILE_CASE_PRESERVED_NAMES | dokan.FILE_UNICODE_ON_DISK
+		if _, ok := asFS[StreamFS](d.fsys); ok && d.opt.Flags&FlagAltStream != 0 {
+			vi.FileSystemFlags |= dokan.FILE_NAMED_STREAMS
+		}
+	}
+	if !isWritable(d.fsys) || d.opt.Flags&FlagsWriteProtect != 0 {
+		vi.FileSystemFlags |= dokan.FILE_READ_ONLY_VOLUME
 	}
-	return dokan.STATUS_NOT_SUPPORTED
+	return vi
 }
 
 func (mi *disk) CreateFile(name string, secCtx uintptr, access, attrs, share, disposition, options uint32, finfo *dokan.FileInfo) (dokan.FileHandle, dokan.NTStatus) {
-	name = strings.TrimPrefix(filepath.ToSlash(name), "/")
-	if name == "" {
-		name = "."
-	}
+	name = normalizeName(name)
+	ctx, cancel := dokan.NewRequestContext(finfo)
+	defer cancel()
 
 	create := disposition == dokan.FILE_CREATE || disposition == dokan.FILE_OPEN_IF || disposition == dokan.FILE_OVERWRITE_IF || disposition == dokan.FILE_SUPERSEDE
 	truncate := disposition == dokan.FILE_SUPERSEDE || disposition == dokan.FILE_OVERWRITE || disposition == dokan.FILE_OVERWRITE_IF
@@ -62,46 +81,71 @@ func (mi *disk) CreateFile(name string, secCtx uintptr, access, attrs, share, di
 		}
 	}
 
-	stat, err := fs.Stat(mi.fsys, name)
+	decision := mi.checkAccess(name, access, disposition, finfo)
+	if decision == AccessDeny || decision == AccessReadOnly && isModifyRequest(access, disposition, options, true) {
+		return nil, dokan.STATUS_ACCESS_DENIED
+	}
+
+	noFollow := options&dokan.FILE_OPEN_REPARSE_POINT != 0
+	var stat fs.FileInfo
+	var err error
+	if noFollow {
+		stat, err = lstatContext(ctx, mi.fsys, name)
+	} else {
+		stat, err = statContext(ctx, mi.fsys, name)
+	}
 	if err != nil && !(create && errors.Is(err, fs.ErrNotExist)) {
 		return nil, dokan.ErrorToNTStatus(err) // Unexpected error
 	}
 	if err == nil && disposition == dokan.FILE_CREATE {
 		return nil, dokan.STATUS_OBJECT_NAME_COLLISION
 	}
-	if err == nil && stat.IsDir() && options&dokan.FILE_NON_DIRECTORY_FILE != 0 {
+	isDir := err == nil && (stat.IsDir() || noFollow && isDirLink(ctx, mi.fsys, name, stat))
+	if err == nil && isDir && options&dokan.FILE_NON_DIRECTORY_FILE != 0 {
 		return nil, dokan.STATUS_FILE_IS_A_DIRECTORY
 	}
-	if err == nil && !stat.IsDir() && options&dokan.FILE_DIRECTORY_FILE != 0 {
+	if err == nil && !isDir && options&dokan.FILE_DIRECTORY_FILE != 0 {
 		return nil, dokan.STATUS_NOT_A_DIRECTORY
 	}
 
-	f := &openedFile{name: name, mi: mi, cachedStat: stat, openFlag: openFlag}
+	if decision == AccessReadOnly && isModifyRequest(access, disposition, options, err == nil) {
+		return nil, dokan.STATUS_ACCESS_DENIED
+	}
+
+	st, status := mi.files.open(name, access, share)
+	if status != dokan.STATUS_SUCCESS {
+		return nil, status
+	}
+	f := &openedFile{name: name, mi: mi, cachedStat: stat, openFlag: openFlag, state: st, access: access, share: share, readOnly: decision == AccessReadOnly, noFollow: noFollow}
 
 	// Mkdir
 	if create && options&dokan.FILE_DIRECTORY_FILE != 0 {
-		if fsys, ok := mi.fsys.(MkdirFS); ok {
-			err = fsys.Mkdir(name, fs.ModePerm)
-			if err != nil {
-				return nil, dokan.ErrorToNTStatus(err)
-			}
-		} else {
-			return nil, dokan.STATUS_NOT_SUPPORTED
+		err = mkdirContext(ctx, mi.fsys, name, fs.ModePerm)
+		if err != nil {
+			mi.files.close(st, access, share)
+			return nil, dokan.ErrorToNTStatus(err)
 		}
 	}
 
 	// NOTE: Reader is not opened here because sometimes it may only need GetFileInformantion()
 	if openFlag != os.O_RDONLY && options&dokan.FILE_DIRECTORY_FILE == 0 {
-		fsys, ok := f.mi.fsys.(OpenWriterFS)
-		if !ok {
+		if !isWritable(mi.fsys) {
 			// Readonly FS. TODO: Consider to return STATUS_NOT_SUPPORTED?
+			mi.files.close(st, access, share)
 			return nil, dokan.STATUS_ACCESS_DENIED
 		}
 		if truncate {
 			f.cachedStat = nil // file size will be cahnged
 		}
-		w, err := fsys.OpenWriter(name, openFlag)
+		if !truncate && stat != nil {
+			if err := hydrate(ctx, mi.fsys, name); err != nil {
+				mi.files.close(st, access, share)
+				return nil, dokan.ErrorToNTStatus(err)
+			}
+		}
+		w, err := openWriterContext(ctx, mi.fsys, name, openFlag)
 		if err != nil {
+			mi.files.close(st, access, share)
 			return nil, dokan.ErrorToNTStatus(err)
 		}
 		f.file = w
@@ -109,3 +153,12 @@ func (mi *disk) CreateFile(name string, secCtx uintptr, access, attrs, share, di
 
 	return f, dokan.STATUS_SUCCESS
 }
+
+// normalizeName converts the path from Dokan to the name in FS.
+func normalizeName(name string) string {
+	name = strings.TrimPrefix(filepath.ToSlash(name), "/")
+	if name == "" {
+		name = "."
+	}
+	return name
+}
@@ -0,0 +1,231 @@
+package dkango
+
+import (
+	"errors"
+	"os"
+	"path"
+	"path/filepath"
+	"testing"
+
+	"github.com/binzume/dkango/dokan"
+)
+
+type testReplaceFs struct {
+	testWritableFs
+	replaced int
+}
+
+func (fsys *testReplaceFs) Replace(name, newName string) error {
+	fsys.replaced++
+	return os.Rename(path.Join(fsys.path, name), path.Join(fsys.path, newName))
+}
+
+func openTestFile(t *testing.T, d *disk, name string, access, disposition, options uint32) *openedFile {
+	t.Helper()
+	share := uint32(dokan.FILE_SHARE_READ | dokan.FILE_SHARE_WRITE | dokan.FILE_SHARE_DELETE)
+	f, status := d.CreateFile(name, 0, access, 0, share, disposition, options, &dokan.FileInfo{})
+	if status != dokan.STATUS_SUCCESS {
+		t.Fatalf("CreateFile(%v) error: %x", name, status)
+	}
+	return f.(*openedFile)
+}
+
+func TestMoveFile_Replace(t *testing.T) {
+	dir := t.TempDir()
+	fsys := &testReplaceFs{testWritableFs: testWritableFs{FS: os.DirFS(dir), path: dir}}
+	d := &disk{opt: &MountOptions{}, fsys: fsys}
+
+	os.WriteFile(filepath.Join(dir, "a.txt"), []byte("aaa"), 0666)
+	os.WriteFile(filepath.Join(dir, "b.txt"), []byte("b"), 0666)
+
+	f := openTestFile(t, d, "/a.txt", dokan.FILE_READ_DATA, dokan.FILE_OPEN, 0)
+	defer f.CloseFile(&dokan.FileInfo{})
+
+	if status := f.MoveFile("/b.txt", false, &dokan.FileInfo{}); status != dokan.STATUS_OBJECT_NAME_COLLISION {
+		t.Errorf("MoveFile() without replace should fail with STATUS_OBJECT_NAME_COLLISION: %x", status)
+	}
+	if fsys.replaced != 0 {
+		t.Error("Replace() should not be called")
+	}
+
+	if status := f.MoveFile("/b.txt", true, &dokan.FileInfo{}); status != dokan.STATUS_SUCCESS {
+		t.Fatalf("MoveFile() error: %x", status)
+	}
+	if fsys.replaced != 1 {
+		t.Error("Replace() should be called")
+	}
+	if f.name != "b.txt" {
+		t.Error("name of opened file should be updated: ", f.name)
+	}
+	if b, _ := os.ReadFile(filepath.Join(dir, "b.txt")); string(b) != "aaa" {
+		t.Error("b.txt should be replaced: ", string(b))
+	}
+
+	if status := f.MoveFile("/c.txt", false, &dokan.FileInfo{}); status != dokan.STATUS_SUCCESS {
+		t.Fatalf("MoveFile() error: %x", status)
+	}
+	if fsys.replaced != 1 {
+		t.Error("Replace() should not be called for new name")
+	}
+}
+
+func TestMoveFile_ReplaceDir(t *testing.T) {
+	dir := t.TempDir()
+	d := &disk{opt: &MountOptions{}, fsys: &testWritableFs{FS: os.DirFS(dir), path: dir}}
+
+	os.WriteFile(filepath.Join(dir, "a.txt"), []byte("aaa"), 0666)
+	os.Mkdir(filepath.Join(dir, "dir"), 0777)
+
+	f := openTestFile(t, d, "/a.txt", dokan.FILE_READ_DATA, dokan.FILE_OPEN, 0)
+	defer f.CloseFile(&dokan.FileInfo{})
+
+	if status := f.MoveFile("/dir", true, &dokan.FileInfo{}); status != dokan.STATUS_ACCESS_DENIED {
+		t.Errorf("MoveFile() to directory should fail with STATUS_ACCESS_DENIED: %x", status)
+	}
+	if status := f.MoveFile("/A.TXT", false, &dokan.FileInfo{}); status != dokan.STATUS_SUCCESS {
+		t.Errorf("MoveFile() for changing case error: %x", status)
+	}
+}
+
+func TestDeletePending(t *testing.T) {
+	dir := t.TempDir()
+	d := &disk{opt: &MountOptions{}, fsys: &testWritableFs{FS: os.DirFS(dir), path: dir}}
+	fname := filepath.Join(dir, "a.txt")
+	os.WriteFile(fname, []byte("aaa"), 0666)
+
+	f1 := openTestFile(t, d, "/a.txt", dokan.FILE_READ_DATA|dokan.DELETE, dokan.FILE_OPEN, 0)
+	f2 := openTestFile(t, d, "/a.txt", dokan.FILE_READ_DATA, dokan.FILE_OPEN, 0)
+	deleteOnClose := &dokan.FileInfo{DeleteOnClose: 1}
+
+	if status := f1.DeleteFile(deleteOnClose); status != dokan.STATUS_SUCCESS {
+		t.Fatalf("DeleteFile() error: %x", status)
+	}
+	if _, status := d.CreateFile("/a.txt", 0, dokan.FILE_READ_DATA, 0, 0, dokan.FILE_OPEN, 0, &dokan.FileInfo{}); status != dokan.STATUS_DELETE_PENDING {
+		t.Errorf("CreateFile() should fail with STATUS_DELETE_PENDING: %x", status)
+	}
+
+	if status := f1.Cleanup(deleteOnClose); status != dokan.STATUS_SUCCESS {
+		t.Fatalf("Cleanup() error: %x", status)
+	}
+	f1.CloseFile(deleteOnClose)
+	if _, err := os.Stat(fname); err != nil {
+		t.Error("file should not be removed while other handles are opened")
+	}
+
+	if status := f2.Cleanup(&dokan.FileInfo{}); status != dokan.STATUS_SUCCESS {
+		t.Fatalf("Cleanup() error: %x", status)
+	}
+	f2.CloseFile(&dokan.FileInfo{})
+	if _, err := os.Stat(fname); !os.IsNotExist(err) {
+		t.Error("file should be removed", err)
+	}
+
+	f3 := openTestFile(t, d, "/a.txt", dokan.FILE_WRITE_DATA, dokan.FILE_CREATE, 0)
+	f3.Cleanup(&dokan.FileInfo{})
+	f3.CloseFile(&dokan.FileInfo{})
+}
+
+func TestDeleteDirectory_NotEmpty(t *testing.T) {
+	dir := t.TempDir()
+	d := &disk{opt: &MountOptions{}, fsys: &testWritableFs{FS: os.DirFS(dir), path: dir}}
+	os.Mkdir(filepath.Join(dir, "dir"), 0777)
+	os.WriteFile(filepath.Join(dir, "dir", "a.txt"), []byte("aaa"), 0666)
+
+	f := openTestFile(t, d, "/dir", dokan.DELETE, dokan.FILE_OPEN, dokan.FILE_DIRECTORY_FILE)
+	defer f.CloseFile(&dokan.FileInfo{})
+	if status := f.DeleteDirectory(&dokan.FileInfo{DeleteOnClose: 1}); status != dokan.STATUS_DIRECTORY_NOT_EMPTY {
+		t.Errorf("DeleteDirectory() should fail with STATUS_DIRECTORY_NOT_EMPTY: %x", status)
+	}
+}
+
+func TestShareAccess(t *testing.T) {
+	dir := t.TempDir()
+	d := &disk{opt: &MountOptions{}, fsys: &testWritableFs{FS: os.DirFS(dir), path: dir}}
+	os.WriteFile(filepath.Join(dir, "a.txt"), []byte("aaa"), 0666)
+
+	f, status := d.CreateFile("/a.txt", 0, dokan.FILE_WRITE_DATA, 0, dokan.FILE_SHARE_READ, dokan.FILE_OPEN, 0, &dokan.FileInfo{})
+	if status != dokan.STATUS_SUCCESS {
+		t.Fatalf("CreateFile() error: %x", status)
+	}
+
+	if _, status := d.CreateFile("/a.txt", 0, dokan.FILE_WRITE_DATA, 0, dokan.FILE_SHARE_READ|dokan.FILE_SHARE_WRITE, dokan.FILE_OPEN, 0, &dokan.FileInfo{}); status != dokan.STATUS_SHARING_VIOLATION {
+		t.Errorf("CreateFile() for writing should fail with STATUS_SHARING_VIOLATION: %x", status)
+	}
+	if _, status := d.CreateFile("/a.txt", 0, dokan.FILE_READ_DATA, 0, dokan.FILE_SHARE_READ, dokan.FILE_OPEN, 0, &dokan.FileInfo{}); status != dokan.STATUS_SHARING_VIOLATION {
+		t.Errorf("CreateFile() without FILE_SHARE_WRITE should fail with STATUS_SHARING_VIOLATION: %x", status)
+	}
+	r := openTestFile(t, d, "/a.txt", dokan.FILE_READ_DATA, dokan.FILE_OPEN, 0)
+	r.Cleanup(&dokan.FileInfo{})
+	r.CloseFile(&dokan.FileInfo{})
+
+	a, status := d.CreateFile("/a.txt", 0, dokan.FILE_READ_ATTRIBUTES, 0, 0, dokan.FILE_OPEN, 0, &dokan.FileInfo{})
+	if status != dokan.STATUS_SUCCESS {
+		t.Errorf("CreateFile() for attributes error: %x", status)
+	} else {
+		a.CloseFile(&dokan.FileInfo{})
+	}
+
+	f.Cleanup(&dokan.FileInfo{})
+	f.CloseFile(&dokan.FileInfo{})
+	w := openTestFile(t, d, "/a.txt", dokan.FILE_WRITE_DATA, dokan.FILE_OPEN, 0)
+	w.CloseFile(&dokan.FileInfo{})
+}
+
+func TestFileStates_CaseInsensitive(t *testing.T) {
+	var s fileStates
+	st, _ := s.open("dir/a.txt", dokan.FILE_WRITE_DATA, dokan.FILE_SHARE_READ)
+	if _, status := s.open("DIR/A.TXT", dokan.FILE_WRITE_DATA, dokan.FILE_SHARE_READ|dokan.FILE_SHARE_WRITE); status != dokan.STATUS_SHARING_VIOLATION {
+		t.Errorf("open() of the case variant should fail with STATUS_SHARING_VIOLATION: %x", status)
+	}
+	s.setDeletePending(st, true)
+	if _, status := s.open("Dir/A.txt", 0, 0); status != dokan.STATUS_DELETE_PENDING {
+		t.Errorf("open() of the case variant should fail with STATUS_DELETE_PENDING: %x", status)
+	}
+	s.setDeletePending(st, false)
+
+	dir, _ := s.open("dir", 0, dokan.FILE_SHARE_READ|dokan.FILE_SHARE_WRITE|dokan.FILE_SHARE_DELETE)
+	if status := s.rename(dir, "Dir2"); status != dokan.STATUS_SUCCESS {
+		t.Fatalf("rename() error: %x", status)
+	}
+	if s.name(st) != "Dir2/a.txt" || s.get("dir2/A.TXT") != st || s.get("dir/a.txt") != nil {
+		t.Error("children should be moved: ", s.name(st))
+	}
+}
+
+func TestMountFS_InvalidOptions(t *testing.T) {
+	_, err := MountFS("X:", os.DirFS("."), &MountOptions{SectorSize: 1000})
+	if !errors.Is(err, dokan.ErrInvalidOption) {
+		t.Error("MountFS() should fail with ErrInvalidOption", err)
+	}
+
+	_, err = MountFS("", os.DirFS("."), &MountOptions{UNCName: `\\server`})
+	if !errors.Is(err, dokan.ErrInvalidOption) {
+		t.Error("MountFS() should fail with ErrInvalidOption", err)
+	}
+
+	_, err = MountFS("", os.DirFS("."), &MountOptions{})
+	if err != dokan.ErrBadMountPoint {
+		t.Error("MountFS() without mount point and UNC name should fail with ErrBadMountPoint", err)
+	}
+}
+
+func TestGetVolumeInformation(t *testing.T) {
+	dir := t.TempDir()
+	d := &disk{opt: &MountOptions{}, fsys: &testWritableFs{FS: os.DirFS(dir), path: dir}}
+	vi, _ := d.GetVolumeInformation(&dokan.FileInfo{})
+	if vi.FileSystemName != "Dokan" || vi.MaximumComponentLength != 255 ||
+		vi.FileSystemFlags != dokan.FILE_CASE_PRESERVED_NAMES|dokan.FILE_UNICODE_ON_DISK {
+		t.Error("invalid default volume information: ", vi)
+	}
+
+	d = &disk{opt: &MountOptions{Flags: FlagsWriteProtect}, fsys: &testWritableFs{FS: os.DirFS(dir), path: dir}}
+	if vi, _ := d.GetVolumeInformation(&dokan.FileInfo{}); vi.FileSystemFlags&dokan.FILE_READ_ONLY_VOLUME == 0 {
+		t.Error("write-protected volume should be read-only: ", vi)
+	}
+
+	d = &disk{opt: &MountOptions{VolumeInfo: dokan.VolumeInformation{FileSystemName: "NTFS", FileSystemFlags: dokan.FILE_CASE_SENSITIVE_SEARCH}}, fsys: os.DirFS(dir)}
+	vi, _ = d.GetVolumeInformation(&dokan.FileInfo{})
+	if vi.FileSystemName != "NTFS" || vi.FileSystemFlags != dokan.FILE_CASE_SENSITIVE_SEARCH|dokan.FILE_READ_ONLY_VOLUME {
+		t.Error("read-only FS should be reported: ", vi)
+	}
+}
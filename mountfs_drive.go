@@ -0,0 +1,36 @@
+package dkango
+
+import (
+	"strings"
+
+	"github.com/binzume/dkango/dokan"
+)
+
+// AutoMountPoint can be passed to MountFS to mount on a free drive letter.
+const AutoMountPoint = "*"
+
+// DefaultDriveLetters is the preference of drive letters used for AutoMountPoint.
+const DefaultDriveLetters = "ZYXWVUTSRQPONMLKJIHGFED"
+
+// FreeDriveLetter returns the first unused drive letter in preferred. e.g. "X:"
+// If preferred is empty, DefaultDriveLetters is used.
+func FreeDriveLetter(preferred string) (string, error) {
+	used, err := dokan.UsedDriveLetters()
+	if err != nil {
+		return "", err
+	}
+	return pickDriveLetter(preferred, used)
+}
+
+func pickDriveLetter(preferred, used string) (string, error) {
+	if preferred == "" {
+		preferred = DefaultDriveLetters
+	}
+	used = strings.ToUpper(used)
+	for _, c := range strings.ToUpper(preferred) {
+		if c >= 'A' && c <= 'Z' && !strings.ContainsRune(used, c) {
+			return string(c) + ":", nil
+		}
+	}
+	return "", dokan.ErrBadDriveLetter
+}
@@ -0,0 +1,28 @@
+package dkango
+
+import (
+	"testing"
+
+	"github.com/binzume/dkango/dokan"
+)
+
+func TestPickDriveLetter(t *testing.T) {
+	tests := []struct {
+		preferred, used, expected string
+	}{
+		{"", "CD", "Z:"},
+		{"", "CDZ", "Y:"},
+		{"xyz", "CDX", "Y:"},
+		{"XYZ", "xyz", ""},
+		{"1X", "", "X:"},
+	}
+	for _, test := range tests {
+		letter, err := pickDriveLetter(test.preferred, test.used)
+		if letter != test.expected {
+			t.Errorf("pickDriveLetter(%q, %q) = %q, expected %q", test.preferred, test.used, letter, test.expected)
+		}
+		if test.expected == "" && err != dokan.ErrBadDriveLetter {
+			t.Error("pickDriveLetter() should fail with ErrBadDriveLetter", err)
+		}
+	}
+}
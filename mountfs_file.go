@@ -1,11 +1,12 @@
 package dkango
 
 import (
+	"context"
+	"errors"
 	"io"
 	"io/fs"
-	"log"
 	"os"
-	"path/filepath"
+	"path"
 	"strings"
 
 	"github.com/binzume/dkango/dokan"
@@ -18,26 +19,40 @@ type openedFile struct {
 	cachedStat fs.FileInfo
 	file       io.Closer
 	pos        int64
+	state      *fileState
+	access     uint32
+	share      uint32
+	readOnly   bool // by PathRules or AccessPolicy
+	noFollow   bool // FILE_OPEN_REPARSE_POINT
 }
 
 func (f *openedFile) FindFiles(fillFindDataCallBack func(fi *dokan.WIN32_FIND_DATAW) (bool, error), finfo *dokan.FileInfo) dokan.NTStatus {
+	ctx, cancel := dokan.NewRequestContext(finfo)
+	defer cancel()
 	proc := func(files []fs.DirEntry) bool {
 		for _, file := range files {
 			fi := dokan.WIN32_FIND_DATAW{}
 			name, err := dokan.UTF16FromString(file.Name())
 			if err != nil {
-				log.Println("ERROR: FindFiles", err)
+				dokan.ReportError(finfo, "FindFiles", err)
 				continue
 			}
 
 			copy(fi.FileName[:], name)
-			if file.IsDir() {
-				fi.FileAttributes = dokan.FILE_ATTRIBUTE_DIRECTORY
-			} else {
-				fi.FileAttributes = dokan.FILE_ATTRIBUTE_NORMAL
-			}
+			fpath := path.Join(f.name, file.Name())
 			info, err := file.Info()
-			if err == nil {
+			if err != nil {
+				info = nil
+			}
+			fi.FileAttributes = f.mi.fileAttributes(fpath, file.IsDir(), info, f.mi.isReadOnlyPath(fpath))
+			if isSymlink(info) {
+				tag := uint32(dokan.IO_REPARSE_TAG_SYMLINK)
+				fi.Reserved0 = int32(tag)
+				if isDirLink(ctx, f.mi.fsys, fpath, info) {
+					fi.FileAttributes |= dokan.FILE_ATTRIBUTE_DIRECTORY
+				}
+			}
+			if info != nil {
 				fi.FileSizeLow = uint32(info.Size())
 				fi.FileSizeHigh = uint32(info.Size() >> 32)
 				fi.LastWriteTime = dokan.UnixNanoToFileTime(info.ModTime().UnixNano())
@@ -49,58 +64,69 @@ func (f *openedFile) FindFiles(fillFindDataCallBack func(fi *dokan.WIN32_FIND_DA
 				return false
 			}
 		}
+		dokan.ReportProgress(ctx)
 		return true
 	}
 
-	if fsys, ok := f.mi.fsys.(OpenDirFS); ok {
-		r, err := fsys.OpenDir(f.name)
-		if err != nil {
-			return dokan.ErrorToNTStatus(err)
-		}
-		for {
-			if files, err := r.ReadDir(256); len(files) == 0 || !proc(files) || err != nil {
-				break
-			}
-		}
-	} else {
-		files, err := fs.ReadDir(f.mi.fsys, f.name)
+	r, err := openDirContext(ctx, f.mi.fsys, f.name)
+	if err != nil {
+		return dokan.ErrorToNTStatus(err)
+	}
+	if r == nil {
+		files, err := readDirContext(ctx, f.mi.fsys, f.name)
 		proc(files)
 		return dokan.ErrorToNTStatus(err)
 	}
-	return dokan.STATUS_SUCCESS
+	defer r.Close()
+	for {
+		if files, err := r.ReadDir(256); len(files) == 0 || !proc(files) || err != nil || ctx.Err() != nil {
+			break
+		}
+	}
+	return dokan.ErrorToNTStatus(ctx.Err())
 }
 
 func (f *openedFile) GetFileInformation(fi *dokan.ByHandleFileInfo, finfo *dokan.FileInfo) dokan.NTStatus {
-
+	ctx, cancel := dokan.NewRequestContext(finfo)
+	defer cancel()
 	if f.cachedStat == nil {
-		stat, err := fs.Stat(f.mi.fsys, f.name)
+		var stat fs.FileInfo
+		var err error
+		if f.noFollow {
+			stat, err = lstatContext(ctx, f.mi.fsys, f.name)
+		} else {
+			stat, err = statContext(ctx, f.mi.fsys, f.name)
+		}
 		f.cachedStat = stat
 		if err != nil {
 			return dokan.ErrorToNTStatus(err)
 		}
 	}
-	if f.cachedStat.IsDir() {
-		fi.FileAttributes = dokan.FILE_ATTRIBUTE_DIRECTORY
-	}
-	if f.cachedStat.Mode()&0o200 == 0 {
-		fi.FileAttributes = dokan.FILE_ATTRIBUTE_READONLY
-	}
-	if fi.FileAttributes == 0 {
-		fi.FileAttributes = dokan.FILE_ATTRIBUTE_NORMAL
-	}
+	isDir := f.cachedStat.IsDir() || f.noFollow && isDirLink(ctx, f.mi.fsys, f.name, f.cachedStat)
+	fi.FileAttributes = f.mi.fileAttributes(f.name, isDir, f.cachedStat, f.readOnly)
 	fi.FileSizeLow = uint32(f.cachedStat.Size())
 	fi.FileSizeHigh = uint32(f.cachedStat.Size() >> 32)
 	fi.LastWriteTime = dokan.UnixNanoToFileTime(f.cachedStat.ModTime().UnixNano())
 	fi.LastAccessTime = fi.LastWriteTime
 	fi.CreationTime = fi.LastWriteTime
 	fi.VolumeSerialNumber = int32(f.mi.opt.VolumeInfo.SerialNumber)
+	id, nlink := f.mi.fileID(f.name, f.cachedStat)
+	fi.FileIndexHigh = int32(id >> 32)
+	fi.FileIndexLow = int32(id)
+	fi.NumberOfLinks = int32(nlink)
 
 	return dokan.STATUS_SUCCESS
 }
 
 func (f *openedFile) ReadFile(buf []byte, read *int32, offset int64, finfo *dokan.FileInfo) dokan.NTStatus {
+	ctx, cancel := dokan.NewRequestContext(finfo)
+	defer cancel()
 	if f.file == nil {
-		r, err := f.mi.fsys.Open(f.name)
+		if err := hydrate(ctx, f.mi.fsys, f.name); err != nil {
+			return dokan.ErrorToNTStatus(err)
+		}
+		f.cachedStat = nil // attributes may be changed by Hydrate()
+		r, err := openContext(ctx, f.mi.fsys, f.name)
 		if err != nil {
 			return dokan.ErrorToNTStatus(err)
 		}
@@ -125,8 +151,7 @@ func (f *openedFile) ReadFile(buf []byte, read *int32, offset int64, finfo *doka
 		}
 	}
 	if r, ok := f.file.(io.Reader); ok {
-		n, err := io.ReadFull(r, buf)
-		// n, err := r.Read(buf)
+		n, err := readFull(ctx, r, buf)
 		f.pos = offset + int64(n)
 		*read = int32(n)
 		if n > 0 {
@@ -160,6 +185,7 @@ func (f *openedFile) WriteFile(buf []byte, written *int32, offset int64, finfo *
 		}
 	}
 	if r, ok := f.file.(io.Writer); ok {
+		f.cachedStat = nil // file size may be changed
 		n, err := r.Write(buf)
 		f.pos = offset + int64(n)
 		*written = int32(n)
@@ -169,54 +195,203 @@ func (f *openedFile) WriteFile(buf []byte, written *int32, offset int64, finfo *
 }
 
 func (f *openedFile) SetEndOfFile(offset int64, finfo *dokan.FileInfo) dokan.NTStatus {
+	if f.state != nil && f.mi.files.allocated(f.state) > offset {
+		f.mi.files.setAllocated(f.state, 0) // deallocated by truncation
+	}
 	if trunc, ok := f.file.(interface{ Truncate(int64) error }); ok {
 		f.cachedStat = nil
 		return dokan.ErrorToNTStatus(trunc.Truncate(offset))
-	} else if fsys, ok := f.mi.fsys.(TruncateFS); ok {
-		f.cachedStat = nil
-		return dokan.ErrorToNTStatus(fsys.Truncate(f.name, offset))
 	}
-	return dokan.STATUS_NOT_SUPPORTED
+	ctx, cancel := dokan.NewRequestContext(finfo)
+	defer cancel()
+	f.cachedStat = nil
+	return dokan.ErrorToNTStatus(truncateContext(ctx, f.mi.fsys, f.name, offset))
 }
 
 func (f *openedFile) MoveFile(newname string, replaceIfExisting bool, finfo *dokan.FileInfo) dokan.NTStatus {
-	fsys, ok := f.mi.fsys.(RenameFS)
-	if !ok {
-		log.Println("WARN: MoveFile: not support Rename()")
+	if !isRenamable(f.mi.fsys) {
+		dokan.ReportError(finfo, "MoveFile", errors.New("not support Rename()"))
 		return dokan.STATUS_NOT_SUPPORTED
 	}
+	ctx, cancel := dokan.NewRequestContext(finfo)
+	defer cancel()
+
+	f.syncName()
+	newname = normalizeName(newname)
+	if f.mi.checkTargetAccess(newname, f.access, replaceIfExisting, finfo) != AccessAllow {
+		return dokan.STATUS_ACCESS_DENIED
+	}
+
+	replace := false
+	if !strings.EqualFold(f.name, newname) { // Allow changing case of the name
+		stat, err := lstatContext(ctx, f.mi.fsys, newname)
+		if err == nil {
+			if !replaceIfExisting {
+				return dokan.STATUS_OBJECT_NAME_COLLISION
+			}
+			if stat.IsDir() {
+				return dokan.STATUS_ACCESS_DENIED
+			}
+			replace = true
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			return dokan.ErrorToNTStatus(err)
+		}
+	}
 
-	newname = strings.TrimPrefix(filepath.ToSlash(newname), "/")
-	if newname == "" {
-		newname = "."
+	if other := f.mi.files.get(newname); other != nil && other != f.state {
+		return dokan.STATUS_ACCESS_DENIED // opened by other handles
 	}
+
 	f.cachedStat = nil
-	return dokan.ErrorToNTStatus(fsys.Rename(f.name, newname))
+	var err error
+	if replace {
+		err = replaceContext(ctx, f.mi.fsys, f.name, newname)
+	} else {
+		err = renameContext(ctx, f.mi.fsys, f.name, newname)
+	}
+	if err != nil {
+		return dokan.ErrorToNTStatus(err)
+	}
+	f.name = newname
+	if f.state != nil {
+		return f.mi.files.rename(f.state, newname)
+	}
+	return dokan.STATUS_SUCCESS
 }
 
 func (f *openedFile) DeleteFile(finfo *dokan.FileInfo) dokan.NTStatus {
-	// will be deleted in Cleanup()
+	if !isRemovable(f.mi.fsys) {
+		return dokan.STATUS_NOT_SUPPORTED
+	}
+	// will be deleted when the last handle is closed
+	if f.state != nil {
+		f.mi.files.setDeletePending(f.state, finfo.IsDeleteOnClose())
+	}
 	return dokan.STATUS_SUCCESS
 }
 
 func (f *openedFile) DeleteDirectory(finfo *dokan.FileInfo) dokan.NTStatus {
-	// will be deleted in Cleanup()
+	if !isRemovable(f.mi.fsys) {
+		return dokan.STATUS_NOT_SUPPORTED
+	}
+	ctx, cancel := dokan.NewRequestContext(finfo)
+	defer cancel()
+	// The link itself is removed even if the target directory is not empty.
+	isLink := false
+	if f.noFollow {
+		info, err := lstatContext(ctx, f.mi.fsys, f.name)
+		isLink = err == nil && isSymlink(info)
+	}
+	if finfo.IsDeleteOnClose() && !isLink {
+		if empty, err := isEmptyDir(ctx, f.mi.fsys, f.name); err != nil {
+			return dokan.ErrorToNTStatus(err)
+		} else if !empty {
+			return dokan.STATUS_DIRECTORY_NOT_EMPTY
+		}
+	}
+	// will be deleted when the last handle is closed
+	if f.state != nil {
+		f.mi.files.setDeletePending(f.state, finfo.IsDeleteOnClose())
+	}
 	return dokan.STATUS_SUCCESS
 }
 
 func (f *openedFile) Cleanup(finfo *dokan.FileInfo) dokan.NTStatus {
-	if !finfo.IsDeleteOnClose() {
+	f.syncName()
+	if finfo.IsDeleteOnClose() {
+		if !isRemovable(f.mi.fsys) {
+			f.release()
+			return dokan.STATUS_NOT_SUPPORTED
+		}
+		if f.state != nil {
+			f.mi.files.setDeletePending(f.state, true)
+		}
+	}
+	if !f.release() {
 		return dokan.STATUS_SUCCESS
 	}
-	if fsys, ok := f.mi.fsys.(RemoveFS); ok {
-		return dokan.ErrorToNTStatus(fsys.Remove(f.name))
+
+	// The last handle of the delete pending file.
+	f.cachedStat = nil
+	if f.file != nil {
+		f.file.Close()
+		f.file = nil
+	}
+	ctx, cancel := dokan.NewRequestContext(finfo)
+	defer cancel()
+	return dokan.ErrorToNTStatus(removeContext(ctx, f.mi.fsys, f.name))
+}
+
+// Flush flushes data written to the file. (implements dokan.Flusher)
+func (f *openedFile) Flush() error {
+	switch w := f.file.(type) {
+	case interface{ Sync() error }:
+		return w.Sync()
+	case interface{ Flush() error }:
+		return w.Flush()
 	}
-	return dokan.STATUS_NOT_SUPPORTED
+	return nil
+}
+
+// syncName updates f.name if the parent directory has been renamed by other handles.
+func (f *openedFile) syncName() {
+	if f.state != nil {
+		f.name = f.mi.files.name(f.state)
+	}
+}
+
+// release unregisters this handle. It returns true if the file should be removed.
+func (f *openedFile) release() bool {
+	if f.state == nil {
+		return false
+	}
+	remove := f.mi.files.close(f.state, f.access, f.share)
+	f.state = nil
+	return remove
 }
 
 func (f *openedFile) CloseFile(*dokan.FileInfo) {
+	f.release()
 	f.cachedStat = nil
 	if f.file != nil {
 		f.file.Close()
 	}
 }
+
+// readFull reads exactly len(buf) bytes from r like io.ReadFull and reports progress of the request.
+func readFull(ctx context.Context, r io.Reader, buf []byte) (n int, err error) {
+	for n < len(buf) && err == nil {
+		var nn int
+		nn, err = r.Read(buf[n:])
+		n += nn
+		if nn > 0 {
+			dokan.ReportProgress(ctx)
+		}
+		if err == nil {
+			err = ctx.Err()
+		}
+	}
+	if n >= len(buf) {
+		err = nil
+	} else if n > 0 && err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return
+}
+
+func isEmptyDir(ctx context.Context, fsys fs.FS, name string) (bool, error) {
+	var files []fs.DirEntry
+	r, err := openDirContext(ctx, fsys, name)
+	if r != nil {
+		files, err = r.ReadDir(1)
+		r.Close()
+	} else if err == nil {
+		files, err = readDirContext(ctx, fsys, name)
+	}
+	if len(files) > 0 {
+		return false, nil
+	} else if err != nil && err != io.EOF {
+		return false, err
+	}
+	return true, nil
+}
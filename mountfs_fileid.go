@@ -0,0 +1,88 @@
+package dkango
+
+import (
+	"errors"
+	"hash/fnv"
+	"io/fs"
+	"strings"
+
+	"github.com/binzume/dkango/dokan"
+)
+
+// FileIDFS is an optional interface to report a stable ID of the file.
+// Hard links to the same file should have the same ID.
+type FileIDFS interface {
+	fs.FS
+	FileID(name string) (uint64, error)
+}
+
+// LinksFileInfo is an optional interface for fs.FileInfo to report the number of hard links.
+type LinksFileInfo interface {
+	fs.FileInfo
+	NumberOfLinks() uint32
+}
+
+// LinkFS is an optional interface to create hard links.
+type LinkFS interface {
+	fs.FS
+	Link(oldname, newname string) error
+}
+
+// fileID returns the ID and the number of links of the file.
+// The ID is taken from FileIDFS, fs.FileInfo.Sys() (inode) or the hash of the path.
+func (d *disk) fileID(name string, info fs.FileInfo) (uint64, uint32) {
+	sysID, nlink, hasSysID := sysFileID(info)
+	if !hasSysID {
+		nlink = 1
+	}
+	if info, ok := info.(LinksFileInfo); ok {
+		nlink = info.NumberOfLinks()
+	}
+	if fsys, ok := asFS[FileIDFS](d.fsys); ok {
+		if id, err := fsys.FileID(name); err == nil {
+			return id, nlink
+		}
+	}
+	if hasSysID {
+		return sysID, nlink
+	}
+	h := fnv.New64a()
+	h.Write([]byte(strings.ToLower(name))) // names are case-insensitive on Windows
+	return h.Sum64(), nlink
+}
+
+// CreateHardLink implements dokan.LinkHandle.
+func (f *openedFile) CreateHardLink(newname string, replaceIfExisting bool, finfo *dokan.FileInfo) dokan.NTStatus {
+	fsys, ok := f.mi.fsys.(LinkFS)
+	if !ok {
+		return dokan.STATUS_NOT_SUPPORTED
+	}
+	if f.readOnly {
+		return dokan.STATUS_ACCESS_DENIED
+	}
+	ctx, cancel := dokan.NewRequestContext(finfo)
+	defer cancel()
+
+	newname = normalizeName(newname)
+	if f.mi.checkTargetAccess(newname, f.access, replaceIfExisting, finfo) != AccessAllow {
+		return dokan.STATUS_ACCESS_DENIED
+	}
+	if stat, err := lstatContext(ctx, f.mi.fsys, newname); err == nil {
+		if !replaceIfExisting {
+			return dokan.STATUS_OBJECT_NAME_COLLISION
+		}
+		if stat.IsDir() || f.mi.files.get(newname) != nil {
+			return dokan.STATUS_ACCESS_DENIED
+		}
+		if err := removeContext(ctx, f.mi.fsys, newname); err != nil {
+			if errors.Is(err, dokan.ErrNotSupported) {
+				return dokan.STATUS_ACCESS_DENIED
+			}
+			return dokan.ErrorToNTStatus(err)
+		}
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return dokan.ErrorToNTStatus(err)
+	}
+	f.cachedStat = nil // number of links is changed
+	return dokan.ErrorToNTStatus(fsys.Link(f.name, newname))
+}
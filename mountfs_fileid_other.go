@@ -0,0 +1,19 @@
+//go:build !windows && !linux && !darwin && !freebsd && !netbsd && !openbsd && !dragonfly
+// +build !windows,!linux,!darwin,!freebsd,!netbsd,!openbsd,!dragonfly
+
+package dkango
+
+import (
+	"errors"
+	"io/fs"
+)
+
+// DirFileID returns the file ID of the file at path.
+// It can be used to implement FileIDFS for a FS based on os.DirFS.
+func DirFileID(path string) (uint64, error) {
+	return 0, errors.New("file id: not supported")
+}
+
+func sysFileID(info fs.FileInfo) (uint64, uint32, bool) {
+	return 0, 0, false
+}
@@ -0,0 +1,136 @@
+package dkango
+
+import (
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"testing/fstest"
+
+	"github.com/binzume/dkango/dokan"
+)
+
+type testHardLinkFs struct {
+	testWritableFs
+}
+
+func (fsys *testHardLinkFs) Link(oldname, newname string) error {
+	return os.Link(path.Join(fsys.path, oldname), path.Join(fsys.path, newname))
+}
+
+type testFileIDFs struct {
+	fstest.MapFS
+}
+
+func (fsys testFileIDFs) FileID(name string) (uint64, error) {
+	if name == "a.txt" {
+		return 0x123456789, nil
+	}
+	return 0, fs.ErrNotExist
+}
+
+func getFileIndex(t *testing.T, d *disk, name string) (uint64, int32) {
+	t.Helper()
+	f := openTestFile(t, d, name, dokan.FILE_READ_ATTRIBUTES, dokan.FILE_OPEN, 0)
+	defer f.CloseFile(&dokan.FileInfo{})
+	var fi dokan.ByHandleFileInfo
+	if status := f.GetFileInformation(&fi, &dokan.FileInfo{}); status != dokan.STATUS_SUCCESS {
+		t.Fatalf("GetFileInformation(%v) error: %x", name, status)
+	}
+	return uint64(uint32(fi.FileIndexHigh))<<32 | uint64(uint32(fi.FileIndexLow)), fi.NumberOfLinks
+}
+
+func TestHardLink(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("file index is not available from os.DirFS on Windows")
+	}
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "a.txt"), []byte("aaa"), 0666)
+	os.WriteFile(filepath.Join(dir, "b.txt"), []byte("b"), 0666)
+	d := &disk{opt: &MountOptions{}, fsys: &testHardLinkFs{testWritableFs{FS: os.DirFS(dir), path: dir}}}
+
+	f := openTestFile(t, d, "/a.txt", dokan.FILE_READ_DATA, dokan.FILE_OPEN, 0)
+	if status := f.CreateHardLink("/b.txt", false, &dokan.FileInfo{}); status != dokan.STATUS_OBJECT_NAME_COLLISION {
+		t.Errorf("CreateHardLink() should fail: %x", status)
+	}
+	if status := f.CreateHardLink("/c.txt", false, &dokan.FileInfo{}); status != dokan.STATUS_SUCCESS {
+		t.Fatalf("CreateHardLink() error: %x", status)
+	}
+	f.CloseFile(&dokan.FileInfo{})
+
+	ro := &disk{opt: &MountOptions{AccessPolicy: func(req *AccessRequest) AccessDecision { return AccessReadOnly }}, fsys: d.fsys}
+	f = openTestFile(t, ro, "/a.txt", dokan.FILE_READ_DATA, dokan.FILE_OPEN, 0)
+	if status := f.CreateHardLink("/d.txt", false, &dokan.FileInfo{}); status != dokan.STATUS_ACCESS_DENIED {
+		t.Errorf("CreateHardLink() by read-only handle should fail: %x", status)
+	}
+	f.CloseFile(&dokan.FileInfo{})
+
+	idA, linksA := getFileIndex(t, d, "/a.txt")
+	idB, linksB := getFileIndex(t, d, "/b.txt")
+	idC, _ := getFileIndex(t, d, "/c.txt")
+	if idA == 0 || idA != idC || idA == idB {
+		t.Errorf("unexpected file index: %x %x %x", idA, idB, idC)
+	}
+	if linksA != 2 || linksB != 1 {
+		t.Errorf("unexpected number of links: %v %v", linksA, linksB)
+	}
+}
+
+func TestFileID(t *testing.T) {
+	mfs := fstest.MapFS{"a.txt": &fstest.MapFile{}, "b.txt": &fstest.MapFile{}}
+	d := &disk{opt: &MountOptions{}, fsys: mfs}
+	id1, links := getFileIndex(t, d, "/b.txt")
+	id2, _ := getFileIndex(t, d, "/b.txt")
+	id3, _ := getFileIndex(t, d, "/a.txt")
+	if id1 == 0 || id1 != id2 || id1 == id3 || links != 1 {
+		t.Errorf("unexpected file index: %x %x %x %v", id1, id2, id3, links)
+	}
+
+	d = &disk{opt: &MountOptions{}, fsys: testFileIDFs{mfs}}
+	if id, _ := getFileIndex(t, d, "/a.txt"); id != 0x123456789 {
+		t.Errorf("file index should be taken from FileIDFS: %x", id)
+	}
+	if id, _ := getFileIndex(t, d, "/b.txt"); id != id1 {
+		t.Errorf("file index should fall back to the hash: %x", id)
+	}
+	f := openTestFile(t, d, "/a.txt", dokan.FILE_READ_DATA, dokan.FILE_OPEN, 0)
+	defer f.CloseFile(&dokan.FileInfo{})
+	if status := f.CreateHardLink("/c.txt", false, &dokan.FileInfo{}); status != dokan.STATUS_NOT_SUPPORTED {
+		t.Errorf("CreateHardLink() should fail: %x", status)
+	}
+}
+
+type testDirFileIDFs struct {
+	testWritableFs
+}
+
+func (fsys *testDirFileIDFs) FileID(name string) (uint64, error) {
+	return DirFileID(filepath.Join(fsys.path, name))
+}
+
+func TestDirFileID(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "a.txt"), []byte("aaa"), 0666)
+	os.WriteFile(filepath.Join(dir, "b.txt"), []byte("b"), 0666)
+	if err := os.Link(filepath.Join(dir, "a.txt"), filepath.Join(dir, "c.txt")); err != nil {
+		t.Skip("hard links are not supported:", err)
+	}
+
+	idA, err := DirFileID(filepath.Join(dir, "a.txt"))
+	if err != nil {
+		t.Fatal("DirFileID() error:", err)
+	}
+	if idC, _ := DirFileID(filepath.Join(dir, "c.txt")); idA != idC {
+		t.Errorf("DirFileID() should be the same for hard links: %x %x", idA, idC)
+	}
+
+	d := &disk{opt: &MountOptions{}, fsys: &testDirFileIDFs{testWritableFs{FS: os.DirFS(dir), path: dir}}}
+	id1, _ := getFileIndex(t, d, "/a.txt")
+	id2, _ := getFileIndex(t, d, "/b.txt")
+	id3, _ := getFileIndex(t, d, "/c.txt")
+	if id1 != idA || id1 != id3 || id1 == id2 {
+		t.Errorf("file index should be taken from FileIDFS: %x %x %x", id1, id2, id3)
+	}
+}
@@ -0,0 +1,35 @@
+//go:build linux || darwin || freebsd || netbsd || openbsd || dragonfly
+// +build linux darwin freebsd netbsd openbsd dragonfly
+
+package dkango
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"syscall"
+)
+
+// DirFileID returns the inode number of the file at path.
+// It can be used to implement FileIDFS for a FS based on os.DirFS.
+func DirFileID(path string) (uint64, error) {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return 0, err
+	}
+	if st, ok := info.Sys().(*syscall.Stat_t); ok {
+		return uint64(st.Ino), nil
+	}
+	return 0, errors.New("file id: not supported")
+}
+
+// sysFileID returns the inode number of the files in os.DirFS.
+func sysFileID(info fs.FileInfo) (uint64, uint32, bool) {
+	if info == nil {
+		return 0, 0, false
+	}
+	if st, ok := info.Sys().(*syscall.Stat_t); ok {
+		return uint64(st.Ino), uint32(st.Nlink), true
+	}
+	return 0, 0, false
+}
@@ -0,0 +1,37 @@
+//go:build windows
+// +build windows
+
+package dkango
+
+import (
+	"io/fs"
+
+	"golang.org/x/sys/windows"
+)
+
+// DirFileID returns the file index of the file at path. Symbolic links are not followed.
+// It can be used to implement FileIDFS for a FS based on os.DirFS.
+// The file is opened to get the index, so the result should be cached if it is called frequently.
+func DirFileID(path string) (uint64, error) {
+	p, err := windows.UTF16PtrFromString(path)
+	if err != nil {
+		return 0, err
+	}
+	// FILE_FLAG_BACKUP_SEMANTICS is required to open directories.
+	flags := uint32(windows.FILE_FLAG_BACKUP_SEMANTICS | windows.FILE_FLAG_OPEN_REPARSE_POINT)
+	h, err := windows.CreateFile(p, 0, windows.FILE_SHARE_READ|windows.FILE_SHARE_WRITE|windows.FILE_SHARE_DELETE, nil, windows.OPEN_EXISTING, flags, 0)
+	if err != nil {
+		return 0, err
+	}
+	defer windows.CloseHandle(h)
+	var fi windows.ByHandleFileInformation
+	if err := windows.GetFileInformationByHandle(h, &fi); err != nil {
+		return 0, err
+	}
+	return uint64(fi.FileIndexHigh)<<32 | uint64(fi.FileIndexLow), nil
+}
+
+// fs.FileInfo of os.DirFS on Windows doesn't contain the file index. Use FileIDFS and DirFileID instead.
+func sysFileID(info fs.FileInfo) (uint64, uint32, bool) {
+	return 0, 0, false
+}
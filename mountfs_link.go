@@ -0,0 +1,116 @@
+package dkango
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"strings"
+
+	"github.com/binzume/dkango/dokan"
+)
+
+// ReadLinkFS is an optional interface to read symbolic links. (compatible with fs.ReadLinkFS in Go 1.25)
+// Symbolic links are reported as reparse points with FILE_ATTRIBUTE_REPARSE_POINT.
+type ReadLinkFS interface {
+	fs.FS
+	// ReadLink returns the target of the symbolic link.
+	ReadLink(name string) (string, error)
+	// Lstat returns fs.FileInfo of the file without following the symbolic link.
+	Lstat(name string) (fs.FileInfo, error)
+}
+
+// SymlinkFS is an optional interface to create symbolic links.
+type SymlinkFS interface {
+	fs.FS
+	Symlink(oldname, newname string) error
+}
+
+// lstatContext doesn't follow the symbolic link if fsys implements ReadLinkFS.
+func lstatContext(ctx context.Context, fsys fs.FS, name string) (fs.FileInfo, error) {
+	if fsys, ok := asFS[ReadLinkFS](fsys); ok {
+		return fsys.Lstat(name)
+	}
+	return statContext(ctx, fsys, name)
+}
+
+func isSymlink(info fs.FileInfo) bool {
+	return info != nil && info.Mode()&fs.ModeSymlink != 0
+}
+
+// isDirLink returns true if info is a symbolic link to a directory.
+// Such links are reported with FILE_ATTRIBUTE_DIRECTORY like directory symbolic links on NTFS.
+func isDirLink(ctx context.Context, fsys fs.FS, name string, info fs.FileInfo) bool {
+	if !isSymlink(info) {
+		return false
+	}
+	st, err := statContext(ctx, fsys, name)
+	return err == nil && st.IsDir()
+}
+
+// GetReparsePoint implements dokan.ReparsePointHandle.
+func (f *openedFile) GetReparsePoint(finfo *dokan.FileInfo) ([]byte, dokan.NTStatus) {
+	fsys, ok := asFS[ReadLinkFS](f.mi.fsys)
+	if !ok {
+		return nil, dokan.STATUS_NOT_A_REPARSE_POINT
+	}
+	info, err := fsys.Lstat(f.name)
+	if err != nil {
+		return nil, dokan.ErrorToNTStatus(err)
+	}
+	if !isSymlink(info) {
+		return nil, dokan.STATUS_NOT_A_REPARSE_POINT
+	}
+	target, err := fsys.ReadLink(f.name)
+	if err != nil {
+		return nil, dokan.ErrorToNTStatus(err)
+	}
+	return dokan.NewSymlinkReparsePoint(target).Encode(), dokan.STATUS_SUCCESS
+}
+
+// SetReparsePoint implements dokan.ReparsePointHandle.
+// The file created by CreateFile is replaced with the symbolic link.
+// The handle must be opened with FILE_WRITE_DATA or FILE_WRITE_ATTRIBUTES, and the file or directory must be empty.
+func (f *openedFile) SetReparsePoint(buf []byte, finfo *dokan.FileInfo) dokan.NTStatus {
+	fsys, ok := f.mi.fsys.(SymlinkFS)
+	if !ok {
+		return dokan.STATUS_NOT_SUPPORTED
+	}
+	if f.access&(dokan.FILE_WRITE_DATA|dokan.FILE_WRITE_ATTRIBUTES) == 0 || f.readOnly {
+		return dokan.STATUS_ACCESS_DENIED
+	}
+	rp, err := dokan.DecodeReparsePoint(buf)
+	if err != nil {
+		return dokan.STATUS_IO_REPARSE_DATA_INVALID
+	}
+	target := rp.LinkTarget()
+	if rp.Relative {
+		target = strings.ReplaceAll(target, `\`, "/")
+	}
+
+	ctx, cancel := dokan.NewRequestContext(finfo)
+	defer cancel()
+	if stat, err := lstatContext(ctx, f.mi.fsys, f.name); err == nil {
+		// Like NTFS, the reparse point can be set only on an empty file or directory.
+		if stat.IsDir() && !isSymlink(stat) {
+			if empty, err := isEmptyDir(ctx, f.mi.fsys, f.name); err != nil {
+				return dokan.ErrorToNTStatus(err)
+			} else if !empty {
+				return dokan.STATUS_DIRECTORY_NOT_EMPTY
+			}
+		} else if stat.Size() > 0 && !isSymlink(stat) || f.pos > 0 {
+			return dokan.STATUS_DIRECTORY_NOT_EMPTY
+		}
+		if f.file != nil {
+			f.file.Close()
+			f.file = nil
+		}
+		if err := removeContext(ctx, f.mi.fsys, f.name); err != nil {
+			if errors.Is(err, dokan.ErrNotSupported) {
+				return dokan.STATUS_ACCESS_DENIED
+			}
+			return dokan.ErrorToNTStatus(err)
+		}
+	}
+	f.cachedStat = nil
+	return dokan.ErrorToNTStatus(fsys.Symlink(target, f.name))
+}
@@ -0,0 +1,138 @@
+package dkango
+
+import (
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"testing"
+
+	"github.com/binzume/dkango/dokan"
+)
+
+type testLinkFs struct {
+	testWritableFs
+}
+
+func (fsys *testLinkFs) ReadLink(name string) (string, error) {
+	return os.Readlink(path.Join(fsys.path, name))
+}
+
+func (fsys *testLinkFs) Lstat(name string) (fs.FileInfo, error) {
+	return os.Lstat(path.Join(fsys.path, name))
+}
+
+func (fsys *testLinkFs) Symlink(oldname, newname string) error {
+	return os.Symlink(oldname, path.Join(fsys.path, newname))
+}
+
+func TestSymlink(t *testing.T) {
+	dir := t.TempDir()
+	os.Mkdir(filepath.Join(dir, "dir"), 0777)
+	os.WriteFile(filepath.Join(dir, "dir", "a.txt"), []byte("aaa"), 0666)
+	if err := os.Symlink("dir/a.txt", filepath.Join(dir, "link.txt")); err != nil {
+		t.Skip("symlink is not supported: ", err)
+	}
+	os.Symlink("dir", filepath.Join(dir, "link_dir"))
+	d := &disk{opt: &MountOptions{}, fsys: &testLinkFs{testWritableFs{FS: os.DirFS(dir), path: dir}}}
+
+	root := openTestFile(t, d, "/", dokan.FILE_READ_DATA, dokan.FILE_OPEN, 0)
+	type entry struct {
+		attrs int32
+		tag   uint32
+	}
+	entries := map[string]entry{}
+	root.FindFiles(func(fi *dokan.WIN32_FIND_DATAW) (bool, error) {
+		entries[findDataName(fi)] = entry{fi.FileAttributes, uint32(fi.Reserved0)}
+		return false, nil
+	}, &dokan.FileInfo{})
+	root.CloseFile(&dokan.FileInfo{})
+	if e := entries["link.txt"]; e.attrs != dokan.FILE_ATTRIBUTE_REPARSE_POINT || e.tag != dokan.IO_REPARSE_TAG_SYMLINK {
+		t.Errorf("unexpected entry of link.txt: %x %x", e.attrs, e.tag)
+	}
+	if e := entries["link_dir"]; e.attrs != dokan.FILE_ATTRIBUTE_REPARSE_POINT|dokan.FILE_ATTRIBUTE_DIRECTORY || e.tag != dokan.IO_REPARSE_TAG_SYMLINK {
+		t.Errorf("unexpected entry of link_dir: %x %x", e.attrs, e.tag)
+	}
+	if e := entries["dir"]; e.attrs != dokan.FILE_ATTRIBUTE_DIRECTORY || e.tag != 0 {
+		t.Errorf("unexpected entry of dir: %x %x", e.attrs, e.tag)
+	}
+
+	// Opening the link follows it.
+	f := openTestFile(t, d, "/link.txt", dokan.FILE_READ_DATA, dokan.FILE_OPEN, 0)
+	var fi dokan.ByHandleFileInfo
+	f.GetFileInformation(&fi, &dokan.FileInfo{})
+	if fi.FileAttributes != dokan.FILE_ATTRIBUTE_NORMAL || fi.FileSizeLow != 3 {
+		t.Errorf("unexpected file information: %x %v", fi.FileAttributes, fi.FileSizeLow)
+	}
+	f.CloseFile(&dokan.FileInfo{})
+
+	f = openTestFile(t, d, "/link.txt", dokan.FILE_READ_ATTRIBUTES, dokan.FILE_OPEN, dokan.FILE_OPEN_REPARSE_POINT)
+	fi = dokan.ByHandleFileInfo{}
+	f.GetFileInformation(&fi, &dokan.FileInfo{})
+	if fi.FileAttributes != dokan.FILE_ATTRIBUTE_REPARSE_POINT {
+		t.Errorf("unexpected attributes: %x", fi.FileAttributes)
+	}
+	buf, status := f.GetReparsePoint(&dokan.FileInfo{})
+	if status != dokan.STATUS_SUCCESS {
+		t.Fatalf("GetReparsePoint() error: %x", status)
+	}
+	rp, err := dokan.DecodeReparsePoint(buf)
+	if err != nil || rp.LinkTarget() != `dir\a.txt` || !rp.Relative {
+		t.Error("unexpected reparse point: ", rp, err)
+	}
+	f.CloseFile(&dokan.FileInfo{})
+
+	f = openTestFile(t, d, "/dir/a.txt", dokan.FILE_READ_DATA, dokan.FILE_OPEN, 0)
+	if _, status := f.GetReparsePoint(&dokan.FileInfo{}); status != dokan.STATUS_NOT_A_REPARSE_POINT {
+		t.Errorf("GetReparsePoint() should fail: %x", status)
+	}
+	f.CloseFile(&dokan.FileInfo{})
+
+	// Remove a directory link like RemoveDirectory.
+	f = openTestFile(t, d, "/link_dir", dokan.DELETE|dokan.FILE_READ_ATTRIBUTES, dokan.FILE_OPEN, dokan.FILE_DIRECTORY_FILE|dokan.FILE_OPEN_REPARSE_POINT)
+	fi = dokan.ByHandleFileInfo{}
+	f.GetFileInformation(&fi, &dokan.FileInfo{})
+	if fi.FileAttributes != dokan.FILE_ATTRIBUTE_REPARSE_POINT|dokan.FILE_ATTRIBUTE_DIRECTORY {
+		t.Errorf("unexpected attributes of link_dir: %x", fi.FileAttributes)
+	}
+	deleteOnClose := &dokan.FileInfo{DeleteOnClose: 1}
+	if status := f.DeleteDirectory(deleteOnClose); status != dokan.STATUS_SUCCESS {
+		t.Errorf("DeleteDirectory() error: %x", status)
+	}
+	f.Cleanup(deleteOnClose)
+	f.CloseFile(deleteOnClose)
+	if _, err := os.Lstat(filepath.Join(dir, "link_dir")); !os.IsNotExist(err) {
+		t.Error("link_dir should be removed: ", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "dir", "a.txt")); err != nil {
+		t.Error("target of the link should not be removed: ", err)
+	}
+
+	// Create a link.
+	f = openTestFile(t, d, "/new_link", dokan.FILE_WRITE_DATA, dokan.FILE_CREATE, 0)
+	if status := f.SetReparsePoint(dokan.NewSymlinkReparsePoint(`dir\a.txt`).Encode(), &dokan.FileInfo{}); status != dokan.STATUS_SUCCESS {
+		t.Fatalf("SetReparsePoint() error: %x", status)
+	}
+	f.CloseFile(&dokan.FileInfo{})
+	if target, err := os.Readlink(filepath.Join(dir, "new_link")); err != nil || target != "dir/a.txt" {
+		t.Error("unexpected link: ", target, err)
+	}
+	if status := openTestFile(t, d, "/dir", dokan.FILE_WRITE_ATTRIBUTES, dokan.FILE_OPEN, 0).SetReparsePoint([]byte{1, 2, 3}, &dokan.FileInfo{}); status != dokan.STATUS_IO_REPARSE_DATA_INVALID {
+		t.Errorf("SetReparsePoint() should fail: %x", status)
+	}
+
+	// Existing content must not be replaced with a link.
+	linkData := dokan.NewSymlinkReparsePoint(`new_link`).Encode()
+	if status := openTestFile(t, d, "/dir/a.txt", dokan.FILE_READ_DATA, dokan.FILE_OPEN, 0).SetReparsePoint(linkData, &dokan.FileInfo{}); status != dokan.STATUS_ACCESS_DENIED {
+		t.Errorf("SetReparsePoint() without write access should fail: %x", status)
+	}
+	if status := openTestFile(t, d, "/dir/a.txt", dokan.FILE_WRITE_DATA, dokan.FILE_OPEN, 0).SetReparsePoint(linkData, &dokan.FileInfo{}); status != dokan.STATUS_DIRECTORY_NOT_EMPTY {
+		t.Errorf("SetReparsePoint() on non-empty file should fail: %x", status)
+	}
+	if status := openTestFile(t, d, "/dir", dokan.FILE_WRITE_ATTRIBUTES, dokan.FILE_OPEN, 0).SetReparsePoint(linkData, &dokan.FileInfo{}); status != dokan.STATUS_DIRECTORY_NOT_EMPTY {
+		t.Errorf("SetReparsePoint() on non-empty directory should fail: %x", status)
+	}
+	if b, err := os.ReadFile(filepath.Join(dir, "dir", "a.txt")); err != nil || string(b) != "aaa" {
+		t.Error("file should not be changed: ", string(b), err)
+	}
+}
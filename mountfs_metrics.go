@@ -0,0 +1,104 @@
+package dkango
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/binzume/dkango/dokan"
+)
+
+// StatsProvider is a source of metrics. *dokan.MountInfo implements this interface.
+type StatsProvider interface {
+	MountPoint() string
+	Stats() dokan.Stats
+}
+
+// MetricsHandler returns a http.Handler which exports metrics of the mounts in Prometheus text format.
+func MetricsHandler(mounts ...StatsProvider) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		WriteMetrics(w, mounts...)
+	})
+}
+
+// WriteMetrics writes metrics of the mounts in Prometheus text format.
+func WriteMetrics(w io.Writer, mounts ...StatsProvider) error {
+	type mountStats struct {
+		label string
+		stats dokan.Stats
+	}
+	var all []mountStats
+	for _, m := range mounts {
+		all = append(all, mountStats{`mount_point="` + escapeLabel(m.MountPoint()) + `"`, m.Stats()})
+	}
+
+	bw := bufio.NewWriter(w)
+	header := func(name, typ, help string) {
+		fmt.Fprintf(bw, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+	}
+
+	header("dkango_operations_total", "counter", "Number of operations.")
+	for _, m := range all {
+		for _, op := range sortedOps(m.stats) {
+			fmt.Fprintf(bw, "dkango_operations_total{%s,op=%q} %d\n", m.label, op, m.stats.Ops[op].Count)
+		}
+	}
+
+	header("dkango_operation_errors_total", "counter", "Number of operations failed by NTSTATUS.")
+	for _, m := range all {
+		for _, op := range sortedOps(m.stats) {
+			errs := m.stats.Ops[op].Errors
+			statuses := make([]dokan.NTStatus, 0, len(errs))
+			for s := range errs {
+				statuses = append(statuses, s)
+			}
+			sort.Slice(statuses, func(i, j int) bool { return statuses[i] < statuses[j] })
+			for _, s := range statuses {
+				fmt.Fprintf(bw, "dkango_operation_errors_total{%s,op=%q,status=\"0x%08X\"} %d\n", m.label, op, uint32(s), errs[s])
+			}
+		}
+	}
+
+	header("dkango_read_bytes_total", "counter", "Bytes read from the volume.")
+	for _, m := range all {
+		fmt.Fprintf(bw, "dkango_read_bytes_total{%s} %d\n", m.label, m.stats.BytesRead)
+	}
+	header("dkango_written_bytes_total", "counter", "Bytes written to the volume.")
+	for _, m := range all {
+		fmt.Fprintf(bw, "dkango_written_bytes_total{%s} %d\n", m.label, m.stats.BytesWritten)
+	}
+
+	header("dkango_operation_duration_seconds", "histogram", "Latency of operations.")
+	for _, m := range all {
+		for _, op := range sortedOps(m.stats) {
+			h := m.stats.Ops[op].Latency
+			for i, b := range h.Bounds {
+				fmt.Fprintf(bw, "dkango_operation_duration_seconds_bucket{%s,op=%q,le=\"%s\"} %d\n",
+					m.label, op, strconv.FormatFloat(b.Seconds(), 'g', -1, 64), h.Counts[i])
+			}
+			count := h.Counts[len(h.Counts)-1]
+			fmt.Fprintf(bw, "dkango_operation_duration_seconds_bucket{%s,op=%q,le=\"+Inf\"} %d\n", m.label, op, count)
+			fmt.Fprintf(bw, "dkango_operation_duration_seconds_sum{%s,op=%q} %s\n", m.label, op, strconv.FormatFloat(h.Sum.Seconds(), 'g', -1, 64))
+			fmt.Fprintf(bw, "dkango_operation_duration_seconds_count{%s,op=%q} %d\n", m.label, op, count)
+		}
+	}
+	return bw.Flush()
+}
+
+func sortedOps(stats dokan.Stats) []string {
+	ops := make([]string, 0, len(stats.Ops))
+	for op := range stats.Ops {
+		ops = append(ops, op)
+	}
+	sort.Strings(ops)
+	return ops
+}
+
+func escapeLabel(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s)
+}
@@ -0,0 +1,58 @@
+package dkango
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/binzume/dkango/dokan"
+)
+
+type testStatsProvider struct {
+	mountPoint string
+	stats      dokan.Stats
+}
+
+func (p *testStatsProvider) MountPoint() string { return p.mountPoint }
+func (p *testStatsProvider) Stats() dokan.Stats { return p.stats }
+
+func TestMetricsHandler(t *testing.T) {
+	counts := make([]uint64, len(dokan.LatencyBuckets)+1)
+	counts[len(counts)-1] = 3
+	stats := dokan.Stats{
+		Ops: map[string]*dokan.OpStats{
+			"ReadFile": {
+				Count:   3,
+				Errors:  map[dokan.NTStatus]uint64{dokan.STATUS_END_OF_FILE: 1},
+				Bytes:   1024,
+				Latency: dokan.Histogram{Bounds: dokan.LatencyBuckets, Counts: counts, Sum: 1500 * time.Millisecond},
+			},
+		},
+		BytesRead: 1024,
+	}
+
+	rec := httptest.NewRecorder()
+	MetricsHandler(&testStatsProvider{`\\server\share`, stats}).ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body := rec.Body.String()
+
+	expected := []string{
+		`# TYPE dkango_operations_total counter`,
+		`dkango_operations_total{mount_point="\\\\server\\share",op="ReadFile"} 3`,
+		`dkango_operation_errors_total{mount_point="\\\\server\\share",op="ReadFile",status="0xC0000011"} 1`,
+		`dkango_read_bytes_total{mount_point="\\\\server\\share"} 1024`,
+		`dkango_written_bytes_total{mount_point="\\\\server\\share"} 0`,
+		`dkango_operation_duration_seconds_bucket{mount_point="\\\\server\\share",op="ReadFile",le="0.0001"} 0`,
+		`dkango_operation_duration_seconds_bucket{mount_point="\\\\server\\share",op="ReadFile",le="+Inf"} 3`,
+		`dkango_operation_duration_seconds_sum{mount_point="\\\\server\\share",op="ReadFile"} 1.5`,
+		`dkango_operation_duration_seconds_count{mount_point="\\\\server\\share",op="ReadFile"} 3`,
+	}
+	for _, line := range expected {
+		if !strings.Contains(body, line+"\n") {
+			t.Errorf("metrics should contain %q\n%s", line, body)
+		}
+	}
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain") {
+		t.Error("unexpected content type: ", ct)
+	}
+}
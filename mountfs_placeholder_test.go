@@ -0,0 +1,112 @@
+package dkango
+
+import (
+	"context"
+	"io/fs"
+	"testing"
+	"testing/fstest"
+
+	"github.com/binzume/dkango/dokan"
+)
+
+type testPlaceholderInfo struct {
+	fs.FileInfo
+	placeholder bool
+}
+
+func (fi *testPlaceholderInfo) IsPlaceholder() bool { return fi.placeholder }
+
+type testPlaceholderEntry struct {
+	fs.DirEntry
+	fsys *testCloudFs
+}
+
+func (e *testPlaceholderEntry) Info() (fs.FileInfo, error) {
+	return e.fsys.Stat(e.Name())
+}
+
+// testCloudFs is a FS whose files are downloaded on Hydrate().
+type testCloudFs struct {
+	fstest.MapFS
+	opened   []string
+	hydrated []string
+}
+
+func (fsys *testCloudFs) Open(name string) (fs.File, error) {
+	fsys.opened = append(fsys.opened, name)
+	return fsys.MapFS.Open(name)
+}
+
+func (fsys *testCloudFs) Stat(name string) (fs.FileInfo, error) {
+	fi, err := fsys.MapFS.Stat(name)
+	if err != nil || fi.IsDir() {
+		return fi, err
+	}
+	return &testPlaceholderInfo{FileInfo: fi, placeholder: !fsys.isHydrated(name)}, nil
+}
+
+func (fsys *testCloudFs) ReadDir(name string) ([]fs.DirEntry, error) {
+	entries, err := fsys.MapFS.ReadDir(name)
+	for i, e := range entries {
+		entries[i] = &testPlaceholderEntry{DirEntry: e, fsys: fsys}
+	}
+	return entries, err
+}
+
+func (fsys *testCloudFs) Hydrate(ctx context.Context, name string) error {
+	fsys.hydrated = append(fsys.hydrated, name)
+	return nil
+}
+
+func (fsys *testCloudFs) isHydrated(name string) bool {
+	for _, n := range fsys.hydrated {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+func TestPlaceholder(t *testing.T) {
+	fsys := &testCloudFs{MapFS: fstest.MapFS{"a.txt": &fstest.MapFile{Data: []byte("hello"), Mode: 0444}}}
+	d := &disk{opt: &MountOptions{}, fsys: fsys}
+	placeholder := int32(dokan.FILE_ATTRIBUTE_READONLY | dokan.FILE_ATTRIBUTE_OFFLINE | dokan.FILE_ATTRIBUTE_RECALL_ON_DATA_ACCESS)
+
+	root := openTestFile(t, d, "/", dokan.FILE_READ_DATA, dokan.FILE_OPEN, 0)
+	var attrs int32
+	root.FindFiles(func(fi *dokan.WIN32_FIND_DATAW) (bool, error) {
+		attrs = fi.FileAttributes
+		return false, nil
+	}, &dokan.FileInfo{})
+	root.CloseFile(&dokan.FileInfo{})
+	if attrs != placeholder {
+		t.Errorf("FindFiles: unexpected attributes: %x", attrs)
+	}
+
+	f := openTestFile(t, d, "/a.txt", dokan.FILE_READ_DATA, dokan.FILE_OPEN, 0)
+	defer f.CloseFile(&dokan.FileInfo{})
+	var fi dokan.ByHandleFileInfo
+	f.GetFileInformation(&fi, &dokan.FileInfo{})
+	if fi.FileAttributes != placeholder {
+		t.Errorf("GetFileInformation: unexpected attributes: %x", fi.FileAttributes)
+	}
+	if len(fsys.opened) != 0 || len(fsys.hydrated) != 0 {
+		t.Fatal("attribute-only queries should not open the content: ", fsys.opened, fsys.hydrated)
+	}
+
+	buf := make([]byte, 10)
+	var n int32
+	if status := f.ReadFile(buf, &n, 0, &dokan.FileInfo{}); status != dokan.STATUS_SUCCESS || string(buf[:n]) != "hello" {
+		t.Fatalf("ReadFile() error: %x", status)
+	}
+	f.ReadFile(buf, &n, 5, &dokan.FileInfo{})
+	if len(fsys.hydrated) != 1 || fsys.hydrated[0] != "a.txt" || len(fsys.opened) != 1 {
+		t.Error("Hydrate() should be called once before reading: ", fsys.hydrated, fsys.opened)
+	}
+
+	fi = dokan.ByHandleFileInfo{}
+	f.GetFileInformation(&fi, &dokan.FileInfo{})
+	if fi.FileAttributes != dokan.FILE_ATTRIBUTE_READONLY {
+		t.Errorf("hydrated file should not be offline: %x", fi.FileAttributes)
+	}
+}
@@ -0,0 +1,475 @@
+package dkango
+
+import (
+	"context"
+	"io"
+	"io/fs"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/binzume/dkango/dokan"
+)
+
+// QuotaFS limits the total size and the number of files of the wrapped FS.
+// Writes and creates exceeding the limit fail with dokan.ErrDiskFull (STATUS_DISK_FULL).
+// The usage is reported by Statfs, so GetDiskFreeSpace shows the remaining quota.
+//
+// QuotaFS implements UnwrapFS, so the wrapped FS decides whether the volume is writable,
+// and its other optional interfaces (e.g. ReadLinkFS, HydrateFS) are used through QuotaFS.
+// Link and Symlink are counted and fail if the wrapped FS doesn't support them.
+// Changes made to the wrapped FS not through QuotaFS are not tracked.
+type QuotaFS struct {
+	fs.FS
+	maxBytes int64
+	maxFiles int64
+
+	lock      sync.Mutex
+	usedBytes int64
+	usedFiles int64
+	writing   map[string]*quotaEntry
+}
+
+// quotaEntry holds the size of the file shared by the writers of the file.
+type quotaEntry struct {
+	name string
+	size int64
+	refs int
+}
+
+// NewQuotaFS returns a QuotaFS with the limits. Zero means unlimited.
+// The current usage is initialized by walking fsys.
+func NewQuotaFS(fsys fs.FS, maxBytes, maxFiles int64) (*QuotaFS, error) {
+	q := &QuotaFS{FS: fsys, maxBytes: maxBytes, maxFiles: maxFiles, writing: map[string]*quotaEntry{}}
+	err := fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || name == "." {
+			return err
+		}
+		q.usedFiles++
+		if d.Type().IsRegular() { // symbolic links don't consume the quota
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			q.usedBytes += info.Size()
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return q, nil
+}
+
+// Unwrap returns the wrapped FS.
+func (q *QuotaFS) Unwrap() fs.FS {
+	return q.FS
+}
+
+// Usage returns the total size and the number of files in the FS.
+func (q *QuotaFS) Usage() (bytes, files int64) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	return q.usedBytes, q.usedFiles
+}
+
+// add updates the usage. Only increases are checked against the limits.
+func (q *QuotaFS) add(bytes, files int64) error {
+	if (bytes > 0 && q.maxBytes > 0 && q.usedBytes+bytes > q.maxBytes) ||
+		(files > 0 && q.maxFiles > 0 && q.usedFiles+files > q.maxFiles) {
+		return dokan.ErrDiskFull
+	}
+	q.usedBytes += bytes
+	q.usedFiles += files
+	return nil
+}
+
+// fileSize returns the size of the file. The size of the file being written is preferred. q.lock must not be held.
+// Symbolic links are not followed and their size is zero, like NewQuotaFS and Symlink count them.
+func (q *QuotaFS) fileSize(ctx context.Context, name string) (int64, error) {
+	info, err := lstatContext(ctx, q.FS, name)
+	if err != nil {
+		return 0, err
+	}
+	if !info.Mode().IsRegular() {
+		return 0, nil
+	}
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	if e := q.writing[name]; e != nil {
+		return e.size, nil
+	}
+	return info.Size(), nil
+}
+
+func (q *QuotaFS) Statfs() (*FSStat, error) {
+	st := &FSStat{}
+	fsys, hasStatfs := asFS[StatfsFS](q.FS)
+	if hasStatfs {
+		s, err := fsys.Statfs()
+		if err != nil {
+			return nil, err
+		}
+		*st = *s
+	}
+	bytes, files := q.Usage()
+	limit := func(total, free, avail *uint64, max, used int64) {
+		if max <= 0 {
+			return
+		}
+		remain := uint64(0)
+		if used < max {
+			remain = uint64(max - used)
+		}
+		*total = uint64(max)
+		if !hasStatfs || remain < *free {
+			*free = remain
+		}
+		if avail != nil && (!hasStatfs || remain < *avail) {
+			*avail = remain
+		}
+	}
+	limit(&st.TotalBytes, &st.FreeBytes, &st.AvailableBytes, q.maxBytes, bytes)
+	limit(&st.Files, &st.FreeFiles, nil, q.maxFiles, files)
+	return st, nil
+}
+
+func (q *QuotaFS) OpenContext(ctx context.Context, name string) (fs.File, error) {
+	return openContext(ctx, q.FS, name)
+}
+
+func (q *QuotaFS) StatContext(ctx context.Context, name string) (fs.FileInfo, error) {
+	return statContext(ctx, q.FS, name)
+}
+
+func (q *QuotaFS) ReadDirContext(ctx context.Context, name string) ([]fs.DirEntry, error) {
+	return readDirContext(ctx, q.FS, name)
+}
+
+func (q *QuotaFS) OpenWriterContext(ctx context.Context, name string, flag int) (io.WriteCloser, error) {
+	size, err := q.fileSize(ctx, name)
+	exists := err == nil
+	var files int64
+	if !exists && flag&os.O_CREATE != 0 {
+		files = 1
+	}
+
+	q.lock.Lock()
+	if err := q.add(0, files); err != nil {
+		q.lock.Unlock()
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+	e := q.writing[name]
+	if e == nil {
+		e = &quotaEntry{name: name, size: size}
+		q.writing[name] = e
+	}
+	e.refs++
+	q.lock.Unlock()
+
+	w, err := openWriterContext(ctx, q.FS, name, flag)
+
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	if err != nil {
+		q.add(0, -files)
+		q.release(e)
+		return nil, err
+	}
+	if !exists {
+		e.size = 0
+	} else if flag&os.O_TRUNC != 0 {
+		q.add(-e.size, 0)
+		e.size = 0
+	}
+	qw := &quotaWriter{q: q, entry: e, w: w, append: flag&os.O_APPEND != 0}
+	if _, ok := w.(io.Seeker); ok {
+		return &quotaSeekWriter{qw}, nil
+	}
+	return qw, nil
+}
+
+// release decrements the reference count of the entry. q.lock must be held.
+func (q *QuotaFS) release(e *quotaEntry) {
+	e.refs--
+	if e.refs == 0 && q.writing[e.name] == e {
+		delete(q.writing, e.name)
+	}
+}
+
+func (q *QuotaFS) RemoveContext(ctx context.Context, name string) error {
+	size, err := q.fileSize(ctx, name)
+	if err != nil {
+		return err
+	}
+	if err := removeContext(ctx, q.FS, name); err != nil {
+		return err
+	}
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	q.add(-size, -1)
+	if e := q.writing[name]; e != nil {
+		e.size = 0 // Open writers are counted from zero again.
+	}
+	return nil
+}
+
+func (q *QuotaFS) RenameContext(ctx context.Context, name, newName string) error {
+	return q.rename(ctx, name, newName, renameContext)
+}
+
+func (q *QuotaFS) ReplaceContext(ctx context.Context, name, newName string) error {
+	return q.rename(ctx, name, newName, replaceContext)
+}
+
+func (q *QuotaFS) rename(ctx context.Context, name, newName string, rename func(context.Context, fs.FS, string, string) error) error {
+	// Renaming to the same file (e.g. changing case) doesn't replace anything.
+	replacedSize, err := q.fileSize(ctx, newName)
+	replaced := err == nil && !strings.EqualFold(name, newName)
+	if err := rename(ctx, q.FS, name, newName); err != nil {
+		return err
+	}
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	if replaced {
+		q.add(-replacedSize, -1)
+		delete(q.writing, newName)
+	}
+	for key, e := range q.writing {
+		if key == name || strings.HasPrefix(key, name+"/") { // files in the renamed directory are moved too
+			delete(q.writing, key)
+			e.name = newName + key[len(name):]
+			q.writing[e.name] = e
+		}
+	}
+	return nil
+}
+
+// Link counts the new link as a file of the same size, as NewQuotaFS does.
+func (q *QuotaFS) Link(oldname, newname string) error {
+	fsys, ok := asFS[LinkFS](q.FS)
+	if !ok {
+		return dokan.ErrNotSupported
+	}
+	size, err := q.fileSize(context.Background(), oldname)
+	if err != nil {
+		return err
+	}
+	q.lock.Lock()
+	err = q.add(size, 1)
+	q.lock.Unlock()
+	if err != nil {
+		return &fs.PathError{Op: "link", Path: newname, Err: err}
+	}
+	if err = fsys.Link(oldname, newname); err != nil {
+		q.lock.Lock()
+		q.add(-size, -1)
+		q.lock.Unlock()
+	}
+	return err
+}
+
+func (q *QuotaFS) Symlink(oldname, newname string) error {
+	fsys, ok := asFS[SymlinkFS](q.FS)
+	if !ok {
+		return dokan.ErrNotSupported
+	}
+	q.lock.Lock()
+	err := q.add(0, 1)
+	q.lock.Unlock()
+	if err != nil {
+		return &fs.PathError{Op: "symlink", Path: newname, Err: err}
+	}
+	if err = fsys.Symlink(oldname, newname); err != nil {
+		q.lock.Lock()
+		q.add(0, -1)
+		q.lock.Unlock()
+	}
+	return err
+}
+
+func (q *QuotaFS) MkdirContext(ctx context.Context, name string, mode fs.FileMode) error {
+	q.lock.Lock()
+	err := q.add(0, 1)
+	q.lock.Unlock()
+	if err != nil {
+		return &fs.PathError{Op: "mkdir", Path: name, Err: err}
+	}
+	err = mkdirContext(ctx, q.FS, name, mode)
+	if err != nil {
+		q.lock.Lock()
+		q.add(0, -1)
+		q.lock.Unlock()
+	}
+	return err
+}
+
+func (q *QuotaFS) TruncateContext(ctx context.Context, name string, size int64) error {
+	current, err := q.fileSize(ctx, name)
+	if err != nil {
+		return err
+	}
+	q.lock.Lock()
+	err = q.add(size-current, 0)
+	q.lock.Unlock()
+	if err != nil {
+		return &fs.PathError{Op: "truncate", Path: name, Err: err}
+	}
+	if err = truncateContext(ctx, q.FS, name, size); err != nil {
+		q.lock.Lock()
+		q.add(current-size, 0)
+		q.lock.Unlock()
+		return err
+	}
+	q.lock.Lock()
+	if e := q.writing[name]; e != nil {
+		e.size = size
+	}
+	q.lock.Unlock()
+	return nil
+}
+
+// quotaWriter counts the bytes written beyond the end of the file.
+type quotaWriter struct {
+	q      *QuotaFS
+	entry  *quotaEntry
+	w      io.WriteCloser
+	pos    int64
+	append bool
+	closed bool
+}
+
+// quotaSeekWriter is used if the writer implements io.Seeker.
+type quotaSeekWriter struct {
+	*quotaWriter
+}
+
+// grow reserves the bytes to extend the file to end.
+func (w *quotaWriter) grow(end int64) (int64, error) {
+	w.q.lock.Lock()
+	defer w.q.lock.Unlock()
+	size := w.entry.size
+	if end <= size {
+		return size, nil
+	}
+	if err := w.q.add(end-size, 0); err != nil {
+		return size, &fs.PathError{Op: "write", Path: w.entry.name, Err: err}
+	}
+	w.entry.size = end
+	return size, nil
+}
+
+// shrink gives back the bytes reserved by grow but not written.
+func (w *quotaWriter) shrink(reserved, end, oldSize int64) {
+	if end >= reserved {
+		return
+	}
+	w.q.lock.Lock()
+	defer w.q.lock.Unlock()
+	if w.entry.size == reserved {
+		if end < oldSize {
+			end = oldSize
+		}
+		w.q.add(end-reserved, 0)
+		w.entry.size = end
+	}
+}
+
+func (w *quotaWriter) Write(p []byte) (int, error) {
+	if w.append {
+		w.q.lock.Lock()
+		w.pos = w.entry.size
+		w.q.lock.Unlock()
+	}
+	oldSize, err := w.grow(w.pos + int64(len(p)))
+	if err != nil {
+		return 0, err
+	}
+	n, err := w.w.Write(p)
+	w.shrink(w.pos+int64(len(p)), w.pos+int64(n), oldSize)
+	w.pos += int64(n)
+	return n, err
+}
+
+func (w *quotaWriter) WriteAt(p []byte, off int64) (int, error) {
+	wa, ok := w.w.(io.WriterAt)
+	if !ok {
+		return 0, dokan.ErrNotSupported
+	}
+	oldSize, err := w.grow(off + int64(len(p)))
+	if err != nil {
+		return 0, err
+	}
+	n, err := wa.WriteAt(p, off)
+	w.shrink(off+int64(len(p)), off+int64(n), oldSize)
+	return n, err
+}
+
+func (w *quotaWriter) Read(p []byte) (int, error) {
+	r, ok := w.w.(io.Reader)
+	if !ok {
+		return 0, dokan.ErrNotSupported
+	}
+	n, err := r.Read(p)
+	w.pos += int64(n)
+	return n, err
+}
+
+func (w *quotaWriter) ReadAt(p []byte, off int64) (int, error) {
+	if r, ok := w.w.(io.ReaderAt); ok {
+		return r.ReadAt(p, off)
+	}
+	return 0, dokan.ErrNotSupported
+}
+
+func (w *quotaSeekWriter) Seek(offset int64, whence int) (int64, error) {
+	pos, err := w.w.(io.Seeker).Seek(offset, whence)
+	if err == nil {
+		w.pos = pos
+	}
+	return pos, err
+}
+
+func (w *quotaWriter) Truncate(size int64) error {
+	trunc, ok := w.w.(interface{ Truncate(int64) error })
+	if !ok {
+		return w.q.TruncateContext(context.Background(), w.entry.name, size)
+	}
+	w.q.lock.Lock()
+	current := w.entry.size
+	err := w.q.add(size-current, 0)
+	w.q.lock.Unlock()
+	if err != nil {
+		return &fs.PathError{Op: "truncate", Path: w.entry.name, Err: err}
+	}
+	if err = trunc.Truncate(size); err != nil {
+		w.q.lock.Lock()
+		w.q.add(current-size, 0)
+		w.q.lock.Unlock()
+		return err
+	}
+	w.q.lock.Lock()
+	w.entry.size = size
+	w.q.lock.Unlock()
+	return nil
+}
+
+func (w *quotaWriter) Sync() error {
+	switch f := w.w.(type) {
+	case interface{ Sync() error }:
+		return f.Sync()
+	case interface{ Flush() error }:
+		return f.Flush()
+	}
+	return nil
+}
+
+func (w *quotaWriter) Close() error {
+	w.q.lock.Lock()
+	if !w.closed {
+		w.closed = true
+		w.q.release(w.entry)
+	}
+	w.q.lock.Unlock()
+	return w.w.Close()
+}
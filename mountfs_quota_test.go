@@ -0,0 +1,169 @@
+package dkango
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/binzume/dkango/dokan"
+)
+
+func TestQuotaFS(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "a.txt"), make([]byte, 100), 0666)
+	os.Mkdir(filepath.Join(dir, "sub"), 0777)
+	os.WriteFile(filepath.Join(dir, "sub", "b.txt"), make([]byte, 50), 0666)
+
+	q, err := NewQuotaFS(&testWritableFs{FS: os.DirFS(dir), path: dir}, 1000, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes, files := q.Usage(); bytes != 150 || files != 3 {
+		t.Fatal("invalid initial usage: ", bytes, files)
+	}
+	d := &disk{opt: &MountOptions{}, fsys: q}
+
+	f := openTestFile(t, d, "/c.txt", dokan.FILE_WRITE_DATA, dokan.FILE_CREATE, 0)
+	var written int32
+	if status := f.WriteFile(make([]byte, 800), &written, 0, &dokan.FileInfo{}); status != dokan.STATUS_SUCCESS || written != 800 {
+		t.Fatalf("WriteFile() error: %x", status)
+	}
+	if status := f.WriteFile(make([]byte, 100), &written, 800, &dokan.FileInfo{}); status != dokan.STATUS_DISK_FULL {
+		t.Errorf("WriteFile() should fail with STATUS_DISK_FULL: %x", status)
+	}
+	if status := f.WriteFile(make([]byte, 100), &written, 100, &dokan.FileInfo{}); status != dokan.STATUS_SUCCESS {
+		t.Errorf("overwriting should not consume quota: %x", status)
+	}
+	if status := f.SetEndOfFile(200, &dokan.FileInfo{}); status != dokan.STATUS_SUCCESS {
+		t.Errorf("SetEndOfFile() error: %x", status)
+	}
+	f.CloseFile(&dokan.FileInfo{})
+	if bytes, files := q.Usage(); bytes != 350 || files != 4 {
+		t.Error("invalid usage: ", bytes, files)
+	}
+
+	var avail, total, free uint64
+	d.GetDiskFreeSpace(&avail, &total, &free, &dokan.FileInfo{})
+	if total != 1000 || free != 650 || avail != 650 {
+		t.Error("invalid disk space: ", avail, total, free)
+	}
+
+	ctx := context.Background()
+	if err := q.MkdirContext(ctx, "dir2", 0777); err != nil {
+		t.Error(err)
+	}
+	if err := q.MkdirContext(ctx, "dir3", 0777); !errors.Is(err, dokan.ErrDiskFull) {
+		t.Error("MkdirContext() should fail: ", err)
+	}
+	if _, status := d.CreateFile("/d.txt", 0, dokan.FILE_WRITE_DATA, 0, 0, dokan.FILE_CREATE, 0, &dokan.FileInfo{}); status != dokan.STATUS_DISK_FULL {
+		t.Errorf("CreateFile() should fail with STATUS_DISK_FULL: %x", status)
+	}
+
+	if err := q.RenameContext(ctx, "c.txt", "a.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if bytes, files := q.Usage(); bytes != 250 || files != 4 {
+		t.Error("replaced file should be released: ", bytes, files)
+	}
+	if err := q.RemoveContext(ctx, "sub/b.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if bytes, files := q.Usage(); bytes != 200 || files != 3 {
+		t.Error("removed file should be released: ", bytes, files)
+	}
+}
+
+func TestQuotaFS_Wrapped(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "a.txt"), make([]byte, 100), 0666)
+
+	q, _ := NewQuotaFS(os.DirFS(dir), 1000, 0)
+	if isWritable(q) || isRemovable(q) || isRenamable(q) {
+		t.Error("QuotaFS should not be writable if the wrapped FS is not")
+	}
+
+	q, _ = NewQuotaFS(&testLinkFs{testWritableFs{FS: os.DirFS(dir), path: dir}}, 1000, 0)
+	if !isWritable(q) {
+		t.Error("QuotaFS should be writable")
+	}
+	if _, ok := asFS[ReadLinkFS](q); !ok {
+		t.Error("ReadLinkFS of the wrapped FS should be used")
+	}
+	if err := q.Link("a.txt", "b.txt"); !errors.Is(err, dokan.ErrNotSupported) {
+		t.Error("Link() should not be supported: ", err)
+	}
+	if err := q.Symlink("a.txt", "link.txt"); err != nil {
+		t.Skip("symlink is not supported: ", err)
+	}
+	if _, files := q.Usage(); files != 2 {
+		t.Error("symlink should be counted: ", files)
+	}
+
+	q, _ = NewQuotaFS(&testHardLinkFs{testWritableFs{FS: os.DirFS(dir), path: dir}}, 1000, 0)
+	bytes, files := q.Usage()
+	if err := q.Link("a.txt", "b.txt"); err != nil {
+		t.Fatal("Link() error: ", err)
+	}
+	if b, f := q.Usage(); b != bytes+100 || f != files+1 {
+		t.Error("hard link should be counted: ", b, f)
+	}
+}
+
+func TestQuotaFS_RenameDir(t *testing.T) {
+	dir := t.TempDir()
+	os.Mkdir(filepath.Join(dir, "sub"), 0777)
+
+	q, _ := NewQuotaFS(&testWritableFs{FS: os.DirFS(dir), path: dir}, 1000, 0)
+	ctx := context.Background()
+	w, err := q.OpenWriterContext(ctx, "sub/a.txt", os.O_WRONLY|os.O_CREATE)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	w.Write(make([]byte, 100))
+
+	if err := q.RenameContext(ctx, "sub", "sub2"); err != nil {
+		t.Fatal(err)
+	}
+	if e := q.writing["sub2/a.txt"]; e == nil || e.name != "sub2/a.txt" || q.writing["sub/a.txt"] != nil {
+		t.Error("the file being written should be moved: ", q.writing)
+	}
+	if err := q.RemoveContext(ctx, "sub2/a.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if bytes, _ := q.Usage(); bytes != 0 {
+		t.Error("invalid usage: ", bytes)
+	}
+}
+
+func TestQuotaFS_Symlink(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "big.txt"), make([]byte, 500), 0666)
+	os.WriteFile(filepath.Join(dir, "small.txt"), make([]byte, 10), 0666)
+	if err := os.Symlink("big.txt", filepath.Join(dir, "link1")); err != nil {
+		t.Skip("symlink is not supported: ", err)
+	}
+
+	q, _ := NewQuotaFS(&testLinkFs{testWritableFs{FS: os.DirFS(dir), path: dir}}, 1000, 0)
+	if bytes, files := q.Usage(); bytes != 510 || files != 3 {
+		t.Fatal("symlink should not consume the quota: ", bytes, files)
+	}
+	ctx := context.Background()
+	if err := q.Symlink("big.txt", "link2"); err != nil {
+		t.Fatal(err)
+	}
+	if err := q.RemoveContext(ctx, "link1"); err != nil {
+		t.Fatal(err)
+	}
+	if bytes, files := q.Usage(); bytes != 510 || files != 3 {
+		t.Error("removing symlink should not release the target: ", bytes, files)
+	}
+	if err := q.RenameContext(ctx, "small.txt", "link2"); err != nil {
+		t.Fatal(err)
+	}
+	if bytes, files := q.Usage(); bytes != 510 || files != 2 {
+		t.Error("replacing symlink should not release the target: ", bytes, files)
+	}
+}
@@ -0,0 +1,174 @@
+package dkango
+
+import (
+	"bufio"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+)
+
+// PathRule is a rule for the paths matched to Pattern.
+//
+// Pattern is a slash-separated glob pattern of path.Match relative to the root of the FS.
+// "**" matches zero or more path elements. e.g. "/archive/**", "**/*.exe"
+// Patterns are matched case-insensitively like paths on Windows.
+type PathRule struct {
+	Pattern  string
+	Decision AccessDecision
+}
+
+// PathRules is a list of PathRule. The first matched rule wins.
+type PathRules []PathRule
+
+// ParsePathRules parses rules in the form of "<pattern> <rw|readonly|deny>" per line.
+// Empty lines and lines starting with # are ignored.
+func ParsePathRules(text string) (PathRules, error) {
+	var rules PathRules
+	scanner := bufio.NewScanner(strings.NewReader(text))
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("line %d: invalid rule: %q", n, line)
+		}
+		var decision AccessDecision
+		switch strings.ToLower(fields[1]) {
+		case "rw", "allow":
+			decision = AccessAllow
+		case "readonly", "ro":
+			decision = AccessReadOnly
+		case "deny":
+			decision = AccessDeny
+		default:
+			return nil, fmt.Errorf("line %d: unknown decision: %q", n, fields[1])
+		}
+		rule := PathRule{Pattern: fields[0], Decision: decision}
+		if err := rule.validate(); err != nil {
+			return nil, fmt.Errorf("line %d: %w", n, err)
+		}
+		rules = append(rules, rule)
+	}
+	return rules, scanner.Err()
+}
+
+func (r *PathRule) validate() error {
+	for _, p := range splitPath(r.Pattern) {
+		if _, err := path.Match(p, ""); err != nil {
+			return fmt.Errorf("%w: %q", err, r.Pattern)
+		}
+	}
+	return nil
+}
+
+// Match returns the decision of the first rule matched to name.
+func (rules PathRules) Match(name string) (AccessDecision, bool) {
+	elems := splitPath(name)
+	for _, r := range rules {
+		if matchElems(splitPath(r.Pattern), elems) {
+			return r.Decision, true
+		}
+	}
+	return AccessAllow, false
+}
+
+func splitPath(p string) []string {
+	p = strings.Trim(p, "/")
+	if p == "" || p == "." {
+		return nil
+	}
+	return strings.Split(p, "/")
+}
+
+func matchElems(pattern, elems []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(elems); i++ {
+				if matchElems(pattern[1:], elems[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(elems) == 0 {
+			return false
+		}
+		if !matchElem(pattern[0], elems[0]) {
+			return false
+		}
+		pattern, elems = pattern[1:], elems[1:]
+	}
+	return len(elems) == 0
+}
+
+// globCache holds the compiled pattern elements. nil for invalid patterns.
+var globCache sync.Map // string -> *regexp.Regexp
+
+// matchElem matches the path element against the pattern element of path.Match case-insensitively.
+func matchElem(pattern, elem string) bool {
+	re, ok := globCache.Load(pattern)
+	if !ok {
+		re, _ = globCache.LoadOrStore(pattern, compileGlob(pattern))
+	}
+	return re.(*regexp.Regexp) != nil && re.(*regexp.Regexp).MatchString(elem)
+}
+
+// compileGlob converts the pattern of path.Match to a case-insensitive regexp.
+// Character classes such as [A-Z] are kept as is, and (?i) makes them case-insensitive too.
+func compileGlob(pattern string) *regexp.Regexp {
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil
+	}
+	var b strings.Builder
+	b.WriteString("(?i)^")
+	inClass := false
+	for i := 0; i < len(pattern); i++ {
+		c := pattern[i]
+		switch {
+		case c == '\\' && i+1 < len(pattern):
+			i++
+			if e := pattern[i]; e < utf8.RuneSelf && !unicode.IsLetter(rune(e)) && !unicode.IsDigit(rune(e)) {
+				b.WriteByte('\\') // escaped punctuation is literal in both classes and the rest
+			}
+			b.WriteByte(pattern[i])
+		case inClass:
+			if c == ']' {
+				inClass = false
+			}
+			if c == '[' {
+				b.WriteByte('\\')
+			}
+			b.WriteByte(c)
+		case c == '[':
+			inClass = true
+			b.WriteByte(c)
+			if i+1 < len(pattern) && pattern[i+1] == '^' {
+				i++
+				b.WriteByte('^')
+			}
+		case c == '*':
+			b.WriteString("[^/]*")
+		case c == '?':
+			b.WriteString("[^/]")
+		default:
+			b.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+		}
+	}
+	b.WriteByte('$')
+	re, err := regexp.Compile(b.String())
+	if err != nil {
+		return nil
+	}
+	return re
+}
+
+func (d *disk) isReadOnlyPath(name string) bool {
+	decision, _ := d.opt.PathRules.Match(name)
+	return decision == AccessReadOnly
+}
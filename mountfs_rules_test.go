@@ -0,0 +1,162 @@
+package dkango
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"unicode/utf16"
+
+	"github.com/binzume/dkango/dokan"
+)
+
+func TestPathRules_Match(t *testing.T) {
+	rules, err := ParsePathRules(`
+# comment
+/archive/**  readonly
+/tmp/**      rw
+**/*.exe     deny
+/a/*/c       ro
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rules) != 4 {
+		t.Fatal("unexpected rules: ", rules)
+	}
+
+	tests := []struct {
+		name     string
+		expected AccessDecision
+		matched  bool
+	}{
+		{".", AccessAllow, false},
+		{"archive", AccessReadOnly, true},
+		{"archive/2020/data.txt", AccessReadOnly, true},
+		{"archive/setup.exe", AccessReadOnly, true}, // first match wins
+		{"archived.txt", AccessAllow, false},
+		{"tmp/setup.exe", AccessAllow, true},
+		{"setup.exe", AccessDeny, true},
+		{"dir/sub/setup.exe", AccessDeny, true},
+		{"setup.exe.txt", AccessAllow, false},
+		{"a/b/c", AccessReadOnly, true},
+		{"a/b/b/c", AccessAllow, false},
+		{"ARCHIVE/a.txt", AccessReadOnly, true}, // case-insensitive
+		{"Dir/Setup.EXE", AccessDeny, true},
+		{"A/B/C", AccessReadOnly, true},
+	}
+	for _, tt := range tests {
+		decision, matched := rules.Match(tt.name)
+		if decision != tt.expected || matched != tt.matched {
+			t.Errorf("Match(%q) = %v, %v, expected %v, %v", tt.name, decision, matched, tt.expected, tt.matched)
+		}
+	}
+
+	for _, text := range []string{"/a", "/a rw extra", "/a write", "/[ ro"} {
+		if _, err := ParsePathRules(text); err == nil {
+			t.Errorf("ParsePathRules(%q) should fail", text)
+		}
+	}
+}
+
+func TestPathRules_MatchPattern(t *testing.T) {
+	tests := []struct {
+		pattern string
+		name    string
+		matched bool
+	}{
+		{"/[A-Z]*.log", "App.log", true},
+		{"/[A-Z]*.log", "app.log", true},
+		{"/[A-Z]*.log", "1.log", false},
+		{"/[^a-c].txt", "d.txt", true},
+		{"/[^a-c].txt", "B.txt", false},
+		{`/file\[1\].txt`, "FILE[1].txt", true},
+		{`/file\[1\].txt`, "file1.txt", false},
+		{`/[\-x]?.CSV`, "-1.csv", true},
+		{"/[Z-a].txt", "_.txt", true}, // broken if the pattern is lowercased
+		{"/data.?", "DATA.X", true},
+		{"/data.?", "dataXx", false},
+		{"/Ä*", "äb", true},
+	}
+	for _, tt := range tests {
+		rules := PathRules{{Pattern: tt.pattern, Decision: AccessDeny}}
+		if _, matched := rules.Match(tt.name); matched != tt.matched {
+			t.Errorf("Match(%q) with %q = %v, expected %v", tt.name, tt.pattern, matched, tt.matched)
+		}
+	}
+}
+
+func TestPathRules_CreateFile(t *testing.T) {
+	dir := t.TempDir()
+	os.Mkdir(filepath.Join(dir, "archive"), 0777)
+	os.WriteFile(filepath.Join(dir, "archive", "a.txt"), []byte("aaa"), 0666)
+	os.WriteFile(filepath.Join(dir, "b.exe"), []byte("b"), 0666)
+	os.WriteFile(filepath.Join(dir, "c.txt"), []byte("c"), 0666)
+	rules, _ := ParsePathRules("/archive/** readonly\n**/*.exe deny")
+	d := &disk{opt: &MountOptions{PathRules: rules}, fsys: &testWritableFs{FS: os.DirFS(dir), path: dir}}
+	share := uint32(dokan.FILE_SHARE_READ | dokan.FILE_SHARE_WRITE | dokan.FILE_SHARE_DELETE)
+
+	if _, status := d.CreateFile("/archive/a.txt", 0, dokan.FILE_WRITE_DATA, 0, share, dokan.FILE_OPEN, 0, &dokan.FileInfo{}); status != dokan.STATUS_ACCESS_DENIED {
+		t.Errorf("writing to read-only path should be denied: %x", status)
+	}
+	if _, status := d.CreateFile("/archive/new.txt", 0, dokan.FILE_READ_DATA, 0, share, dokan.FILE_OPEN_IF, 0, &dokan.FileInfo{}); status != dokan.STATUS_ACCESS_DENIED {
+		t.Errorf("creating a file in read-only path should be denied: %x", status)
+	}
+	if _, status := d.CreateFile("/b.exe", 0, dokan.FILE_READ_DATA, 0, share, dokan.FILE_OPEN, 0, &dokan.FileInfo{}); status != dokan.STATUS_ACCESS_DENIED {
+		t.Errorf("opening denied path should fail: %x", status)
+	}
+	if _, status := d.CreateFile("/B.EXE", 0, dokan.FILE_READ_DATA, 0, share, dokan.FILE_OPEN, 0, &dokan.FileInfo{}); status != dokan.STATUS_ACCESS_DENIED {
+		t.Errorf("opening denied path in different case should fail: %x", status)
+	}
+	if _, status := d.CreateFile("/ARCHIVE/new.txt", 0, dokan.FILE_WRITE_DATA, 0, share, dokan.FILE_CREATE, 0, &dokan.FileInfo{}); status != dokan.STATUS_ACCESS_DENIED {
+		t.Errorf("creating a file in read-only path in different case should be denied: %x", status)
+	}
+
+	f := openTestFile(t, d, "/archive/a.txt", dokan.FILE_READ_DATA, dokan.FILE_OPEN, 0)
+	var fi dokan.ByHandleFileInfo
+	if status := f.GetFileInformation(&fi, &dokan.FileInfo{}); status != dokan.STATUS_SUCCESS || fi.FileAttributes != dokan.FILE_ATTRIBUTE_READONLY {
+		t.Errorf("read-only file should have FILE_ATTRIBUTE_READONLY: %x %x", status, fi.FileAttributes)
+	}
+	f.CloseFile(&dokan.FileInfo{})
+
+	f = openTestFile(t, d, "/c.txt", dokan.DELETE, dokan.FILE_OPEN, 0)
+	if status := f.MoveFile("/archive/c.txt", false, &dokan.FileInfo{}); status != dokan.STATUS_ACCESS_DENIED {
+		t.Errorf("moving a file to read-only path should be denied: %x", status)
+	}
+	if status := f.MoveFile("/Archive/c.txt", false, &dokan.FileInfo{}); status != dokan.STATUS_ACCESS_DENIED {
+		t.Errorf("moving a file to read-only path in different case should be denied: %x", status)
+	}
+	f.CloseFile(&dokan.FileInfo{})
+
+	d.fsys = &testHardLinkFs{testWritableFs: testWritableFs{FS: os.DirFS(dir), path: dir}}
+	f = openTestFile(t, d, "/c.txt", dokan.FILE_READ_DATA, dokan.FILE_OPEN, 0)
+	if status := f.CreateHardLink("/ARCHIVE/c.txt", false, &dokan.FileInfo{}); status != dokan.STATUS_ACCESS_DENIED {
+		t.Errorf("linking a file to read-only path in different case should be denied: %x", status)
+	}
+	f.CloseFile(&dokan.FileInfo{})
+	d.fsys = &testWritableFs{FS: os.DirFS(dir), path: dir}
+
+	root := openTestFile(t, d, "/", dokan.FILE_READ_DATA, dokan.FILE_OPEN, 0)
+	defer root.CloseFile(&dokan.FileInfo{})
+	attrs := map[string]int32{}
+	root.FindFiles(func(fi *dokan.WIN32_FIND_DATAW) (bool, error) {
+		attrs[findDataName(fi)] = fi.FileAttributes
+		return false, nil
+	}, &dokan.FileInfo{})
+	if attrs["archive"] != dokan.FILE_ATTRIBUTE_DIRECTORY|dokan.FILE_ATTRIBUTE_READONLY {
+		t.Errorf("unexpected attributes of archive: %x", attrs["archive"])
+	}
+	if attrs["c.txt"] != dokan.FILE_ATTRIBUTE_NORMAL {
+		t.Errorf("unexpected attributes of c.txt: %x", attrs["c.txt"])
+	}
+}
+
+func findDataName(fi *dokan.WIN32_FIND_DATAW) string {
+	name := fi.FileName[:]
+	for i, c := range name {
+		if c == 0 {
+			name = name[:i]
+			break
+		}
+	}
+	return string(utf16.Decode(name))
+}
@@ -0,0 +1,98 @@
+package dkango
+
+import (
+	"io"
+	"io/fs"
+
+	"github.com/binzume/dkango/dokan"
+)
+
+// SparseFile is an optional interface for the writers returned by OpenWriter to deallocate the range.
+// The file size must not be changed by PunchHole.
+type SparseFile interface {
+	PunchHole(offset, length int64) error
+}
+
+// PunchHoleFS is an optional interface to deallocate the range of the file.
+// The file size must not be changed by PunchHole.
+type PunchHoleFS interface {
+	fs.FS
+	PunchHole(name string, offset, length int64) error
+}
+
+// SparseFileInfo is an optional interface for fs.FileInfo to report sparse files.
+type SparseFileInfo interface {
+	fs.FileInfo
+	IsSparse() bool
+}
+
+func (f *openedFile) canPunchHole() bool {
+	if _, ok := f.file.(SparseFile); ok {
+		return true
+	}
+	_, ok := asFS[PunchHoleFS](f.mi.fsys)
+	return ok
+}
+
+// SetSparse implements dokan.SparseHandle.
+func (f *openedFile) SetSparse(sparse bool, finfo *dokan.FileInfo) dokan.NTStatus {
+	if f.access&writeAccess == 0 || f.readOnly {
+		return dokan.STATUS_ACCESS_DENIED
+	}
+	if sparse && !f.canPunchHole() {
+		return dokan.STATUS_NOT_SUPPORTED
+	}
+	return dokan.STATUS_SUCCESS
+}
+
+// ZeroRange implements dokan.SparseHandle.
+// If the range can not be deallocated, zeros are written to the range within the file size.
+func (f *openedFile) ZeroRange(offset, length int64, finfo *dokan.FileInfo) dokan.NTStatus {
+	if offset < 0 || length < 0 {
+		return dokan.STATUS_INVALID_PARAMETER
+	}
+	if f.access&writeAccess == 0 || f.readOnly {
+		return dokan.STATUS_ACCESS_DENIED
+	}
+	if length == 0 {
+		return dokan.STATUS_SUCCESS
+	}
+	f.cachedStat = nil
+	if sf, ok := f.file.(SparseFile); ok {
+		return dokan.ErrorToNTStatus(sf.PunchHole(offset, length))
+	}
+	if fsys, ok := asFS[PunchHoleFS](f.mi.fsys); ok {
+		return dokan.ErrorToNTStatus(fsys.PunchHole(f.name, offset, length))
+	}
+	w, ok := f.file.(io.WriterAt)
+	if !ok {
+		return dokan.STATUS_NOT_SUPPORTED
+	}
+
+	ctx, cancel := dokan.NewRequestContext(finfo)
+	defer cancel()
+	stat, err := statContext(ctx, f.mi.fsys, f.name)
+	if err != nil {
+		return dokan.ErrorToNTStatus(err)
+	}
+	end := offset + length
+	if end > stat.Size() || end < offset {
+		end = stat.Size()
+	}
+	zeros := make([]byte, 64*1024)
+	for offset < end {
+		n := int64(len(zeros))
+		if end-offset < n {
+			n = end - offset
+		}
+		if _, err := w.WriteAt(zeros[:n], offset); err != nil {
+			return dokan.ErrorToNTStatus(err)
+		}
+		offset += n
+		dokan.ReportProgress(ctx)
+		if ctx.Err() != nil {
+			return dokan.ErrorToNTStatus(ctx.Err())
+		}
+	}
+	return dokan.STATUS_SUCCESS
+}
@@ -0,0 +1,58 @@
+package dkango
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/binzume/dkango/dokan"
+)
+
+type testPunchHoleFs struct {
+	testWritableFs
+	holes [][2]int64
+}
+
+func (fsys *testPunchHoleFs) PunchHole(name string, offset, length int64) error {
+	fsys.holes = append(fsys.holes, [2]int64{offset, length})
+	return nil
+}
+
+func TestZeroRange(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "a.txt"), []byte("0123456789"), 0666)
+	d := &disk{opt: &MountOptions{}, fsys: &testWritableFs{FS: os.DirFS(dir), path: dir}}
+
+	f := openTestFile(t, d, "/a.txt", dokan.FILE_READ_DATA, dokan.FILE_OPEN, 0)
+	if status := f.ZeroRange(0, 5, &dokan.FileInfo{}); status != dokan.STATUS_ACCESS_DENIED {
+		t.Errorf("ZeroRange() without write access should fail: %x", status)
+	}
+	f.CloseFile(&dokan.FileInfo{})
+
+	f = openTestFile(t, d, "/a.txt", dokan.FILE_READ_DATA|dokan.FILE_WRITE_DATA, dokan.FILE_OPEN, 0)
+	if status := f.SetSparse(true, &dokan.FileInfo{}); status != dokan.STATUS_NOT_SUPPORTED {
+		t.Errorf("SetSparse() should fail: %x", status)
+	}
+	if status := f.ZeroRange(2, 3, &dokan.FileInfo{}); status != dokan.STATUS_SUCCESS {
+		t.Fatalf("ZeroRange() error: %x", status)
+	}
+	if status := f.ZeroRange(8, 100, &dokan.FileInfo{}); status != dokan.STATUS_SUCCESS {
+		t.Fatalf("ZeroRange() error: %x", status)
+	}
+	f.CloseFile(&dokan.FileInfo{})
+	if b, _ := os.ReadFile(filepath.Join(dir, "a.txt")); !bytes.Equal(b, []byte("01\x00\x00\x00567\x00\x00")) {
+		t.Errorf("unexpected content: %q", b)
+	}
+
+	fsys := &testPunchHoleFs{testWritableFs: testWritableFs{FS: os.DirFS(dir), path: dir}}
+	d = &disk{opt: &MountOptions{}, fsys: fsys}
+	f = openTestFile(t, d, "/a.txt", dokan.FILE_WRITE_DATA, dokan.FILE_OPEN, 0)
+	defer f.CloseFile(&dokan.FileInfo{})
+	if status := f.SetSparse(true, &dokan.FileInfo{}); status != dokan.STATUS_SUCCESS {
+		t.Errorf("SetSparse() error: %x", status)
+	}
+	if status := f.ZeroRange(4096, 8192, &dokan.FileInfo{}); status != dokan.STATUS_SUCCESS || len(fsys.holes) != 1 || fsys.holes[0] != [2]int64{4096, 8192} {
+		t.Errorf("PunchHole() should be called: %x %v", status, fsys.holes)
+	}
+}
@@ -0,0 +1,174 @@
+package dkango
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/binzume/dkango/dokan"
+)
+
+const (
+	readAccess   = dokan.FILE_READ_DATA | dokan.FILE_EXECUTE
+	writeAccess  = dokan.FILE_WRITE_DATA | dokan.FILE_APPEND_DATA
+	deleteAccess = dokan.DELETE
+)
+
+// fileState is shared by all handles opened for the same path.
+type fileState struct {
+	name          string
+	handles       int
+	deletePending bool
+	allocated     int64 // preallocated size by SetAllocationSize
+
+	// for share access check
+	opened       int
+	readers      int
+	writers      int
+	deleters     int
+	sharedRead   int
+	sharedWrite  int
+	sharedDelete int
+}
+
+// fileStates tracks files opened in the disk.
+// The map is keyed by stateKey(name) because names on Windows are case-insensitive.
+type fileStates struct {
+	lock  sync.Mutex
+	files map[string]*fileState
+}
+
+func stateKey(name string) string {
+	return strings.ToLower(name)
+}
+
+func (s *fileStates) get(name string) *fileState {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.files[stateKey(name)]
+}
+
+// name returns the current name of the file. It is changed when the file or its parent directory is renamed.
+func (s *fileStates) name(st *fileState) string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return st.name
+}
+
+// open registers a new handle for name. access and share are requested access mask and share mode.
+func (s *fileStates) open(name string, access, share uint32) (*fileState, dokan.NTStatus) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.files == nil {
+		s.files = map[string]*fileState{}
+	}
+	st := s.files[stateKey(name)]
+	if st == nil {
+		st = &fileState{name: name}
+	}
+	if st.deletePending {
+		return nil, dokan.STATUS_DELETE_PENDING
+	}
+
+	read, write, del := access&readAccess != 0, access&writeAccess != 0, access&deleteAccess != 0
+	if read || write || del {
+		if read && st.sharedRead < st.opened ||
+			write && st.sharedWrite < st.opened ||
+			del && st.sharedDelete < st.opened ||
+			st.readers != 0 && share&dokan.FILE_SHARE_READ == 0 ||
+			st.writers != 0 && share&dokan.FILE_SHARE_WRITE == 0 ||
+			st.deleters != 0 && share&dokan.FILE_SHARE_DELETE == 0 {
+			return nil, dokan.STATUS_SHARING_VIOLATION
+		}
+	}
+	st.updateShareAccess(access, share, 1)
+	st.handles++
+	s.files[stateKey(name)] = st
+	return st, dokan.STATUS_SUCCESS
+}
+
+// close unregisters a handle. It returns true if the file should be removed.
+func (s *fileStates) close(st *fileState, access, share uint32) bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	st.updateShareAccess(access, share, -1)
+	st.handles--
+	if st.handles > 0 {
+		return false
+	}
+	if key := stateKey(st.name); s.files[key] == st {
+		delete(s.files, key)
+	}
+	return st.deletePending
+}
+
+func (s *fileStates) setDeletePending(st *fileState, pending bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	st.deletePending = pending
+}
+
+// allocated returns the preallocated size of the file.
+func (s *fileStates) allocated(st *fileState) int64 {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return st.allocated
+}
+
+// setAllocated updates the preallocated size of the file.
+func (s *fileStates) setAllocated(st *fileState, size int64) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	st.allocated = size
+}
+
+// rename moves state of st and the files under it to newName. It fails if newName is opened by other handles.
+func (s *fileStates) rename(st *fileState, newName string) dokan.NTStatus {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	oldKey, newKey := stateKey(st.name), stateKey(newName)
+	if other := s.files[newKey]; other != nil && other != st {
+		return dokan.STATUS_ACCESS_DENIED
+	}
+	if s.files[oldKey] == st {
+		delete(s.files, oldKey)
+	}
+	if oldKey != newKey {
+		prefix := oldKey + "/"
+		for key, child := range s.files {
+			if strings.HasPrefix(key, prefix) {
+				delete(s.files, key)
+				child.name = newName + child.name[len(st.name):]
+				s.files[newKey+key[len(oldKey):]] = child
+			}
+		}
+	}
+	st.name = newName
+	s.files[newKey] = st
+	return dokan.STATUS_SUCCESS
+}
+
+func (st *fileState) updateShareAccess(access, share uint32, n int) {
+	read, write, del := access&readAccess != 0, access&writeAccess != 0, access&deleteAccess != 0
+	if !read && !write && !del {
+		return
+	}
+	st.opened += n
+	if read {
+		st.readers += n
+	}
+	if write {
+		st.writers += n
+	}
+	if del {
+		st.deleters += n
+	}
+	if share&dokan.FILE_SHARE_READ != 0 {
+		st.sharedRead += n
+	}
+	if share&dokan.FILE_SHARE_WRITE != 0 {
+		st.sharedWrite += n
+	}
+	if share&dokan.FILE_SHARE_DELETE != 0 {
+		st.sharedDelete += n
+	}
+}
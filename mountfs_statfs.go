@@ -0,0 +1,94 @@
+package dkango
+
+import (
+	"io/fs"
+	"time"
+
+	"github.com/binzume/dkango/dokan"
+)
+
+// FSStat represents the capacity of a file system.
+// Files and FreeFiles are the number of file nodes (e.g. inodes). Zero if unknown.
+type FSStat struct {
+	TotalBytes     uint64
+	FreeBytes      uint64
+	AvailableBytes uint64 // Free bytes available to the caller
+	Files          uint64
+	FreeFiles      uint64
+}
+
+// An interface to get the capacity of the file system.
+// It is used for GetDiskFreeSpace unless MountOptions.DiskSpaceFunc is specified.
+type StatfsFS interface {
+	fs.FS
+	Statfs() (*FSStat, error)
+}
+
+// MemStatfs returns the capacity of an in-memory file system limited to capacity bytes.
+// It can be used to implement StatfsFS for a memory-backed FS (e.g. fstest.MapFS), which has no volume to query.
+// The used size is the sum of the sizes of the regular files in fsys.
+//
+// MemStatfs walks the whole fsys, so it costs O(number of files) per call.
+// The result of Statfs is cached by the disk for statfsCacheDuration (2s), but a large FS should keep
+// a running total of the used size and return it from Statfs instead.
+func MemStatfs(fsys fs.FS, capacity uint64) (*FSStat, error) {
+	var used uint64
+	err := fs.WalkDir(fsys, ".", func(path string, d fs.DirEntry, err error) error {
+		if err != nil || !d.Type().IsRegular() {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		used += uint64(info.Size())
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	free := uint64(0)
+	if used < capacity {
+		free = capacity - used
+	}
+	return &FSStat{TotalBytes: capacity, FreeBytes: free, AvailableBytes: free}, nil
+}
+
+// The result of Statfs is cached for statfsCacheDuration to avoid calling it for every request from Explorer.
+const statfsCacheDuration = 2 * time.Second
+
+func (d *disk) statfs(fsys StatfsFS) (*FSStat, error) {
+	d.statfsMu.Lock()
+	defer d.statfsMu.Unlock()
+	if d.statfsCache != nil && time.Since(d.statfsTime) < statfsCacheDuration {
+		return d.statfsCache, nil
+	}
+	st, err := fsys.Statfs()
+	if err != nil {
+		return nil, err
+	}
+	d.statfsCache, d.statfsTime = st, time.Now()
+	return st, nil
+}
+
+func (d *disk) GetDiskFreeSpace(availableBytes *uint64, totalBytes *uint64, freeBytes *uint64, finfo *dokan.FileInfo) dokan.NTStatus {
+	if (d.opt.DiskSpaceFunc) != nil {
+		space := d.opt.DiskSpaceFunc()
+		*availableBytes = space.FreeBytesAvailable
+		*totalBytes = space.TotalNumberOfBytes
+		*freeBytes = space.TotalNumberOfFreeBytes
+		return dokan.STATUS_SUCCESS
+	}
+	fsys, ok := d.fsys.(StatfsFS)
+	if !ok {
+		return dokan.STATUS_NOT_SUPPORTED
+	}
+	st, err := d.statfs(fsys)
+	if err != nil {
+		return dokan.ErrorToNTStatus(err)
+	}
+	*availableBytes = st.AvailableBytes
+	*totalBytes = st.TotalBytes
+	*freeBytes = st.FreeBytes
+	return dokan.STATUS_SUCCESS
+}
@@ -0,0 +1,13 @@
+//go:build !linux && !darwin && !freebsd && !windows
+// +build !linux,!darwin,!freebsd,!windows
+
+package dkango
+
+import (
+	"errors"
+)
+
+// DirStatfs is not supported on this platform.
+func DirStatfs(dir string) (*FSStat, error) {
+	return nil, errors.New("statfs: not supported")
+}
@@ -0,0 +1,67 @@
+package dkango
+
+import (
+	"os"
+	"testing"
+	"testing/fstest"
+
+	"github.com/binzume/dkango/dokan"
+)
+
+type testStatfsFs struct {
+	testWritableFs
+	called int
+}
+
+func (fsys *testStatfsFs) Statfs() (*FSStat, error) {
+	fsys.called++
+	return DirStatfs(fsys.path)
+}
+
+func TestGetDiskFreeSpace(t *testing.T) {
+	dir := t.TempDir()
+	var avail, total, free uint64
+
+	d := &disk{opt: &MountOptions{}, fsys: os.DirFS(dir)}
+	if status := d.GetDiskFreeSpace(&avail, &total, &free, &dokan.FileInfo{}); status != dokan.STATUS_NOT_SUPPORTED {
+		t.Errorf("GetDiskFreeSpace() should not be supported: %x", status)
+	}
+
+	fsys := &testStatfsFs{testWritableFs: testWritableFs{FS: os.DirFS(dir), path: dir}}
+	d = &disk{opt: &MountOptions{}, fsys: fsys}
+	if status := d.GetDiskFreeSpace(&avail, &total, &free, &dokan.FileInfo{}); status != dokan.STATUS_SUCCESS {
+		t.Fatalf("GetDiskFreeSpace() error: %x", status)
+	}
+	if total == 0 || free > total || avail > free {
+		t.Error("invalid disk space: ", avail, total, free)
+	}
+	d.GetDiskFreeSpace(&avail, &total, &free, &dokan.FileInfo{})
+	if fsys.called != 1 {
+		t.Error("Statfs() should be cached: ", fsys.called)
+	}
+
+	d = &disk{opt: &MountOptions{DiskSpaceFunc: func() DiskSpace { return DiskSpace{TotalNumberOfBytes: 123} }}, fsys: fsys}
+	d.GetDiskFreeSpace(&avail, &total, &free, &dokan.FileInfo{})
+	if total != 123 {
+		t.Error("DiskSpaceFunc should be preferred: ", total)
+	}
+}
+
+func TestMemStatfs(t *testing.T) {
+	mfs := fstest.MapFS{
+		"a.txt":     &fstest.MapFile{Data: []byte("0123456789")},
+		"dir/b.txt": &fstest.MapFile{Data: []byte("abc")},
+	}
+	st, err := MemStatfs(mfs, 100)
+	if err != nil {
+		t.Fatal("MemStatfs() error:", err)
+	}
+	if st.TotalBytes != 100 || st.FreeBytes != 87 || st.AvailableBytes != 87 {
+		t.Error("unexpected capacity: ", st)
+	}
+
+	st, _ = MemStatfs(mfs, 10)
+	if st.FreeBytes != 0 {
+		t.Error("free bytes should be zero if the capacity is exceeded: ", st.FreeBytes)
+	}
+}
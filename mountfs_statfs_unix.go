@@ -0,0 +1,25 @@
+//go:build linux || darwin || freebsd
+// +build linux darwin freebsd
+
+package dkango
+
+import (
+	"golang.org/x/sys/unix"
+)
+
+// DirStatfs returns the capacity of the file system containing dir.
+// It can be used to implement StatfsFS for a FS based on os.DirFS.
+func DirStatfs(dir string) (*FSStat, error) {
+	var st unix.Statfs_t
+	if err := unix.Statfs(dir, &st); err != nil {
+		return nil, err
+	}
+	bsize := uint64(st.Bsize)
+	return &FSStat{
+		TotalBytes:     uint64(st.Blocks) * bsize,
+		FreeBytes:      uint64(st.Bfree) * bsize,
+		AvailableBytes: uint64(st.Bavail) * bsize,
+		Files:          uint64(st.Files),
+		FreeFiles:      uint64(st.Ffree),
+	}, nil
+}
@@ -0,0 +1,23 @@
+//go:build windows
+// +build windows
+
+package dkango
+
+import (
+	"golang.org/x/sys/windows"
+)
+
+// DirStatfs returns the capacity of the volume containing dir.
+// It can be used to implement StatfsFS for a FS based on os.DirFS.
+// Files and FreeFiles are not available on Windows.
+func DirStatfs(dir string) (*FSStat, error) {
+	p, err := windows.UTF16PtrFromString(dir)
+	if err != nil {
+		return nil, err
+	}
+	var st FSStat
+	if err := windows.GetDiskFreeSpaceEx(p, &st.AvailableBytes, &st.TotalBytes, &st.FreeBytes); err != nil {
+		return nil, err
+	}
+	return &st, nil
+}
@@ -0,0 +1,56 @@
+package dkango
+
+import (
+	"io/fs"
+
+	"github.com/binzume/dkango/dokan"
+)
+
+// StreamFS is an optional interface to support alternate data streams.
+// If fsys implements StreamFS and FlagAltStream is set, FILE_NAMED_STREAMS is reported
+// and names in the form of "name:streamname" are passed to the other methods of fsys.
+type StreamFS interface {
+	fs.FS
+	// Streams returns the named streams of the file. Name() of each entry is the stream name (e.g. "Zone.Identifier").
+	Streams(name string) ([]fs.FileInfo, error)
+}
+
+// FindStreams implements dokan.StreamsHandle.
+// The default data stream of the file is reported as "::$DATA".
+func (f *openedFile) FindStreams(fillFindStreamDataCallBack func(fs *dokan.WIN32_FIND_STREAM_DATA) (bool, error), finfo *dokan.FileInfo) dokan.NTStatus {
+	fsys, ok := asFS[StreamFS](f.mi.fsys)
+	if !ok {
+		return dokan.STATUS_NOT_IMPLEMENTED
+	}
+	ctx, cancel := dokan.NewRequestContext(finfo)
+	defer cancel()
+	stat, err := statContext(ctx, f.mi.fsys, f.name)
+	if err != nil {
+		return dokan.ErrorToNTStatus(err)
+	}
+	streams, err := fsys.Streams(f.name)
+	if err != nil {
+		return dokan.ErrorToNTStatus(err)
+	}
+
+	fill := func(name string, size int64) bool {
+		s, err := dokan.UTF16FromString(name)
+		if err != nil || len(s) > len(dokan.WIN32_FIND_STREAM_DATA{}.StreamName) {
+			dokan.ReportError(finfo, "FindStreams", fs.ErrInvalid)
+			return true
+		}
+		fd := dokan.WIN32_FIND_STREAM_DATA{StreamSize: size}
+		copy(fd.StreamName[:], s)
+		bufferFull, err := fillFindStreamDataCallBack(&fd)
+		return err == nil && !bufferFull
+	}
+	if !stat.IsDir() && !fill("::$DATA", stat.Size()) {
+		return dokan.STATUS_SUCCESS
+	}
+	for _, st := range streams {
+		if !fill(":"+st.Name()+":$DATA", st.Size()) {
+			break
+		}
+	}
+	return dokan.STATUS_SUCCESS
+}
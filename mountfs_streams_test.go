@@ -0,0 +1,84 @@
+package dkango
+
+import (
+	"fmt"
+	"io/fs"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"unicode/utf16"
+
+	"github.com/binzume/dkango/dokan"
+)
+
+type testStreamFs struct {
+	fstest.MapFS
+}
+
+type testStreamInfo struct {
+	fs.FileInfo
+	name string
+}
+
+func (info *testStreamInfo) Name() string { return info.name }
+
+// Streams returns the entries named "name:streamname".
+func (fsys testStreamFs) Streams(name string) ([]fs.FileInfo, error) {
+	var streams []fs.FileInfo
+	for key := range fsys.MapFS {
+		if strings.HasPrefix(key, name+":") {
+			info, err := fs.Stat(fsys.MapFS, key)
+			if err != nil {
+				return nil, err
+			}
+			streams = append(streams, &testStreamInfo{FileInfo: info, name: key[len(name)+1:]})
+		}
+	}
+	return streams, nil
+}
+
+func TestFindStreams(t *testing.T) {
+	mfs := fstest.MapFS{
+		"a.txt":                 &fstest.MapFile{Data: []byte("aaa")},
+		"a.txt:Zone.Identifier": &fstest.MapFile{Data: []byte("[ZoneTransfer]")},
+		"dir/b.txt":             &fstest.MapFile{},
+	}
+	d := &disk{opt: &MountOptions{Flags: FlagAltStream}, fsys: testStreamFs{mfs}}
+	if vi, _ := d.GetVolumeInformation(&dokan.FileInfo{}); vi.FileSystemFlags&dokan.FILE_NAMED_STREAMS == 0 {
+		t.Error("FILE_NAMED_STREAMS should be reported: ", vi.FileSystemFlags)
+	}
+
+	findStreams := func(name string) []string {
+		f := openTestFile(t, d, name, dokan.FILE_READ_ATTRIBUTES, dokan.FILE_OPEN, 0)
+		defer f.CloseFile(&dokan.FileInfo{})
+		var streams []string
+		status := f.FindStreams(func(fs *dokan.WIN32_FIND_STREAM_DATA) (bool, error) {
+			streams = append(streams, fmt.Sprintf("%s=%d", strings.TrimRight(string(utf16.Decode(fs.StreamName[:])), "\x00"), fs.StreamSize))
+			return false, nil
+		}, &dokan.FileInfo{})
+		if status != dokan.STATUS_SUCCESS {
+			t.Errorf("FindStreams(%v) error: %x", name, status)
+		}
+		return streams
+	}
+	if streams := findStreams("/a.txt"); strings.Join(streams, ",") != "::$DATA=3,:Zone.Identifier:$DATA=14" {
+		t.Error("unexpected streams: ", streams)
+	}
+	if streams := findStreams("/dir"); len(streams) != 0 {
+		t.Error("directory should not have the default stream: ", streams)
+	}
+
+	d = &disk{opt: &MountOptions{}, fsys: testStreamFs{mfs}}
+	if vi, _ := d.GetVolumeInformation(&dokan.FileInfo{}); vi.FileSystemFlags&dokan.FILE_NAMED_STREAMS != 0 {
+		t.Error("FILE_NAMED_STREAMS requires FlagAltStream: ", vi.FileSystemFlags)
+	}
+	d = &disk{opt: &MountOptions{Flags: FlagAltStream}, fsys: mfs}
+	if vi, _ := d.GetVolumeInformation(&dokan.FileInfo{}); vi.FileSystemFlags&dokan.FILE_NAMED_STREAMS != 0 {
+		t.Error("FILE_NAMED_STREAMS requires StreamFS: ", vi.FileSystemFlags)
+	}
+	f := openTestFile(t, d, "/a.txt", dokan.FILE_READ_ATTRIBUTES, dokan.FILE_OPEN, 0)
+	defer f.CloseFile(&dokan.FileInfo{})
+	if status := f.FindStreams(nil, &dokan.FileInfo{}); status != dokan.STATUS_NOT_IMPLEMENTED {
+		t.Errorf("FindStreams() should not be implemented: %x", status)
+	}
+}
@@ -0,0 +1,140 @@
+package dkango
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"time"
+
+	"github.com/binzume/dkango/dokan"
+)
+
+// Mount represents a mounted volume. *dokan.MountInfo implements this interface.
+type Mount interface {
+	Done() <-chan struct{}
+	Err() error
+	Close() error
+}
+
+type SupervisorState int
+
+const (
+	StateMounting SupervisorState = iota
+	StateMounted
+	StateRetrying
+	StateStopped
+)
+
+func (s SupervisorState) String() string {
+	switch s {
+	case StateMounting:
+		return "Mounting"
+	case StateMounted:
+		return "Mounted"
+	case StateRetrying:
+		return "Retrying"
+	case StateStopped:
+		return "Stopped"
+	}
+	return "Unknown"
+}
+
+// SupervisorEvent represents a state transition of Supervisor.
+type SupervisorEvent struct {
+	State      SupervisorState
+	MountPoint string
+	Err        error // the reason of retrying
+}
+
+// Supervisor keeps fsys mounted. It remounts fsys when it is unmounted unexpectedly.
+type Supervisor struct {
+	// Preferred mount point first, followed by fallbacks used when the mount point is already used.
+	MountPoints []string
+	FS          fs.FS
+	Options     *MountOptions
+
+	// Backoff of retrying. (default: 1s ~ 1min)
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+
+	// State transitions are sent to Events if not nil. Events are dropped if the channel is full.
+	Events chan<- SupervisorEvent
+
+	// MountFunc is used to mount FS. (default: MountFS)
+	MountFunc func(mountPoint string, fsys fs.FS, opt *MountOptions) (Mount, error)
+}
+
+// Run mounts FS and keeps it mounted until ctx is done.
+// The volume is unmounted before Run returns ctx.Err().
+func (s *Supervisor) Run(ctx context.Context) error {
+	if len(s.MountPoints) == 0 {
+		return dokan.ErrBadMountPoint
+	}
+	mount := s.MountFunc
+	if mount == nil {
+		mount = func(mountPoint string, fsys fs.FS, opt *MountOptions) (Mount, error) {
+			return MountFS(mountPoint, fsys, opt)
+		}
+	}
+	minBackoff, maxBackoff := s.MinBackoff, s.MaxBackoff
+	if minBackoff <= 0 {
+		minBackoff = time.Second
+	}
+	if maxBackoff < minBackoff {
+		maxBackoff = minBackoff * 60
+	}
+
+	backoff := minBackoff
+	index := 0
+	for {
+		mountPoint := s.MountPoints[index]
+		s.emit(SupervisorEvent{State: StateMounting, MountPoint: mountPoint})
+		m, err := mount(mountPoint, s.FS, s.Options)
+		if err == nil {
+			s.emit(SupervisorEvent{State: StateMounted, MountPoint: mountPoint})
+			backoff = minBackoff
+			select {
+			case <-ctx.Done():
+				m.Close()
+				s.emit(SupervisorEvent{State: StateStopped, MountPoint: mountPoint})
+				return ctx.Err()
+			case <-m.Done():
+				err = m.Err()
+				m.Close()
+				if err == nil {
+					err = dokan.ErrUnmounted
+				}
+			}
+			index = 0
+		} else if (errors.Is(err, dokan.ErrBadDriveLetter) || errors.Is(err, dokan.ErrMount)) && index+1 < len(s.MountPoints) {
+			index++ // try next mount point immediately
+			continue
+		} else {
+			index = 0
+		}
+
+		s.emit(SupervisorEvent{State: StateRetrying, MountPoint: mountPoint, Err: err})
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			s.emit(SupervisorEvent{State: StateStopped, MountPoint: mountPoint})
+			return ctx.Err()
+		case <-timer.C:
+		}
+		backoff *= 2
+		if backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+}
+
+func (s *Supervisor) emit(ev SupervisorEvent) {
+	if s.Events == nil {
+		return
+	}
+	select {
+	case s.Events <- ev:
+	default:
+	}
+}
@@ -0,0 +1,98 @@
+package dkango
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"testing"
+	"time"
+
+	"github.com/binzume/dkango/dokan"
+)
+
+type testMount struct {
+	done   chan struct{}
+	err    error
+	closed bool
+}
+
+func (m *testMount) Done() <-chan struct{} { return m.done }
+func (m *testMount) Err() error            { return m.err }
+func (m *testMount) Close() error {
+	m.closed = true
+	return nil
+}
+
+func TestSupervisor(t *testing.T) {
+	mounts := make(chan *testMount, 10)
+	errFailed := errors.New("failed")
+	calls := 0
+	events := make(chan SupervisorEvent, 100)
+	s := &Supervisor{
+		MountPoints: []string{"X:", "Y:"},
+		MinBackoff:  time.Millisecond,
+		MaxBackoff:  4 * time.Millisecond,
+		Events:      events,
+		MountFunc: func(mountPoint string, fsys fs.FS, opt *MountOptions) (Mount, error) {
+			calls++
+			switch calls {
+			case 1:
+				return nil, dokan.ErrBadDriveLetter // X: is used
+			case 3:
+				return nil, errFailed
+			}
+			m := &testMount{done: make(chan struct{})}
+			mounts <- m
+			return m, nil
+		},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	result := make(chan error)
+	go func() { result <- s.Run(ctx) }()
+
+	m1 := <-mounts
+	m1.err = dokan.ErrUnmounted
+	close(m1.done) // ejected
+	m2 := <-mounts
+	cancel()
+
+	if err := <-result; err != context.Canceled {
+		t.Error("Run() should return context.Canceled", err)
+	}
+	if !m1.closed || !m2.closed {
+		t.Error("mounts should be closed")
+	}
+
+	expected := []SupervisorEvent{
+		{State: StateMounting, MountPoint: "X:"},
+		{State: StateMounting, MountPoint: "Y:"},
+		{State: StateMounted, MountPoint: "Y:"},
+		{State: StateRetrying, MountPoint: "Y:", Err: dokan.ErrUnmounted},
+		{State: StateMounting, MountPoint: "X:"},
+		{State: StateRetrying, MountPoint: "X:", Err: errFailed},
+		{State: StateMounting, MountPoint: "X:"},
+		{State: StateMounted, MountPoint: "X:"},
+		{State: StateStopped, MountPoint: "X:"},
+	}
+	close(events)
+	var actual []SupervisorEvent
+	for ev := range events {
+		actual = append(actual, ev)
+	}
+	if len(actual) != len(expected) {
+		t.Fatal("unexpected events: ", actual)
+	}
+	for i, ev := range expected {
+		if actual[i] != ev {
+			t.Errorf("event[%d] = %v, expected %v", i, actual[i], ev)
+		}
+	}
+}
+
+func TestSupervisor_NoMountPoints(t *testing.T) {
+	s := &Supervisor{}
+	if err := s.Run(context.Background()); err != dokan.ErrBadMountPoint {
+		t.Error("Run() should fail with ErrBadMountPoint", err)
+	}
+}
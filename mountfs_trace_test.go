@@ -0,0 +1,60 @@
+package dkango
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/binzume/dkango/dokan"
+)
+
+func TestRecordReplay(t *testing.T) {
+	dir := t.TempDir()
+	var trace bytes.Buffer
+	rec := dokan.NewRecorder(&disk{opt: &MountOptions{}, fsys: &testWritableFs{FS: os.DirFS(dir), path: dir}}, &trace)
+	rec.RecordData = true
+
+	finfo := &dokan.FileInfo{}
+	share := uint32(dokan.FILE_SHARE_READ | dokan.FILE_SHARE_WRITE)
+	f, status := rec.CreateFile("/a.txt", 0, dokan.FILE_WRITE_DATA, 0, share, dokan.FILE_CREATE, 0, finfo)
+	if status != dokan.STATUS_SUCCESS {
+		t.Fatalf("CreateFile() error: %x", status)
+	}
+	var n int32
+	f.WriteFile([]byte("hello"), &n, 0, finfo)
+	f.Cleanup(finfo)
+	f.CloseFile(finfo)
+
+	if _, status := rec.CreateFile("/notfound.txt", 0, dokan.FILE_READ_DATA, 0, share, dokan.FILE_OPEN, 0, &dokan.FileInfo{}); status != dokan.STATUS_OBJECT_NAME_NOT_FOUND {
+		t.Fatalf("CreateFile() should fail: %x", status)
+	}
+	if rec.Err() != nil {
+		t.Fatal(rec.Err())
+	}
+	if lines := strings.Count(trace.String(), "\n"); lines != 5 {
+		t.Fatal("unexpected trace: ", trace.String())
+	}
+
+	// Same results on writable FS.
+	dir2 := t.TempDir()
+	diffs, err := dokan.Replay(&disk{opt: &MountOptions{}, fsys: &testWritableFs{FS: os.DirFS(dir2), path: dir2}}, bytes.NewReader(trace.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diffs) != 0 {
+		t.Error("unexpected diffs: ", diffs)
+	}
+	if b, _ := os.ReadFile(dir2 + "/a.txt"); string(b) != "hello" {
+		t.Error("unexpected content: ", string(b))
+	}
+
+	// CreateFile fails on read-only FS.
+	diffs, err = dokan.Replay(&disk{opt: &MountOptions{}, fsys: os.DirFS(t.TempDir())}, bytes.NewReader(trace.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diffs) != 1 || diffs[0].Entry.Op != "CreateFile" || diffs[0].Actual != dokan.STATUS_ACCESS_DENIED {
+		t.Error("unexpected diffs: ", diffs)
+	}
+}